@@ -43,24 +43,310 @@ const (
 
 	// ArgResourceFilter is the name of flag to specify a resource string
 	ArgResourceFilter = "filter"
+
+	// ArgExcludeFilter is the name of flag to specify resources to exclude
+	ArgExcludeFilter = "exclude"
+
+	// ArgProvider is the name of flag to select resources by provider configuration
+	ArgProvider = "provider"
+
+	// ArgRegex is the name of flag to select resources to refactor with a regular expression
+	ArgRegex = "regex"
+
+	// ArgWhere is the name of flag to select resource instances by attribute values
+	ArgWhere = "where"
+
+	// ArgPerResource is the name of flag to generate one moved directive per resource instead of per module
+	ArgPerResource = "per-resource"
+
+	// ArgToForEach is the name of flag to convert resources created with count to for_each
+	ArgToForEach = "to-for-each"
+
+	// ArgKey is the name of flag to specify the template deriving for_each keys
+	ArgKey = "key"
+
+	// ArgToCount is the name of flag to convert resources created with for_each to count
+	ArgToCount = "to-count"
+
+	// ArgOrder is the name of flag to specify how for_each keys are numbered when converted to count
+	ArgOrder = "order"
+
+	// ArgToSingleton is the name of flag to convert resources holding a single instance to resources without count nor for_each
+	ArgToSingleton = "to-singleton"
+
+	// ArgMapping is the name of flag to specify a file declaring several moves
+	ArgMapping = "mapping"
+
+	// ArgDryRun is the name of flag to display the effect of moves on the state instead of generating them
+	ArgDryRun = "dry-run"
+
+	// ArgFormat is the name of flag to specify how moves are rendered
+	ArgFormat = "format"
+
+	// ArgStatePath is the name of flag to specify the state given to terraform state mv with -state
+	ArgStatePath = "state"
+
+	// ArgStateOutPath is the name of flag to specify the state given to terraform state mv with -state-out
+	ArgStateOutPath = "state-out"
+
+	// ArgImportIDs is the name of flag to specify a file of import ID templates per resource type
+	ArgImportIDs = "import-ids"
+
+	// ArgImportsOut is the name of flag to specify the file where import blocks of removed resources are written
+	ArgImportsOut = "imports-out"
+
+	// ArgRule is the name of flag to specify a rule selecting resources moved to a new state
+	ArgRule = "rule"
+
+	// ArgOutDir is the name of flag to specify the directory where new files are written
+	ArgOutDir = "out-dir"
+
+	// ArgArtifacts is the name of flag to also generate the configuration blocks migrating resources between states
+	ArgArtifacts = "artifacts"
+
+	// ArgOut is the name of flag to specify the path of the state written
+	ArgOut = "out"
+
+	// ArgInto is the name of flag to specify the module where the resources of a merged state are moved
+	ArgInto = "into"
+
+	// ArgPlan is the name of flag to specify a terraform plan written by terraform show -json
+	ArgPlan = "plan"
+
+	// ArgThreshold is the name of flag to specify the minimal confidence of suggested moves
+	ArgThreshold = "threshold"
+
+	// ArgShowSensitive is the name of flag to display sensitive values
+	ArgShowSensitive = "show-sensitive"
+
+	// ArgForce is the name of flag to replace an existing state file by a new state
+	ArgForce = "force"
 )
 
 // Args represents lists different options for one argument (Description, Short, DefaultValue)
 var Args = map[string]arguments{
 	ArgTFStateFile: {
-		Description:  "(required) Path of the terraform state in json",
+		Description:  "(required) Path of the terraform state in json, or of the output of terraform show -json",
 		Short:        "t",
 		DefaultValue: "",
 	},
 	ArgResourceFilter: {
-		Description:  "(optional) Filter string to apply - Example: resource.datadog_synthetics_private_location.main",
+		Description:  "(optional) Filter string to apply, globs allowed, repeat to select resources matching any filter - Example: module.*.datadog_synthetics_private_location.main",
 		Short:        "f",
 		DefaultValue: "",
 	},
+	ArgExcludeFilter: {
+		Description:  "(optional) Filter string of resources to exclude, globs allowed, repeatable - Example: data.*.*",
+		Short:        "e",
+		DefaultValue: "",
+	},
+	ArgProvider: {
+		Description:  "(optional) Provider configuration of resources, as [module.name.][[hostname/]namespace/]type[.alias], a trailing dot selects the default configuration only - Example: hashicorp/aws.eu_west",
+		Short:        "p",
+		DefaultValue: "",
+	},
+	ArgRegex: {
+		Description:  "(optional) Regular expression matching addresses of resources to refactor, new_location may then reference capture groups ($1, ${name}) - Example: module\\.old_(\\w+)\\.aws_sqs_queue\\.(\\w+)",
+		Short:        "r",
+		DefaultValue: "",
+	},
+	ArgWhere: {
+		Description:  "(optional) Expression on instance attributes, sensitive attributes never match - Example: tags.team == \"payments\" && instance_type =~ \"^m5\"",
+		Short:        "w",
+		DefaultValue: "",
+	},
+	ArgPerResource: {
+		Description:  "(optional) Generate one moved directive per resource instance even when a whole module is moved",
+		Short:        "",
+		DefaultValue: "false",
+	},
+	ArgToForEach: {
+		Description:  "(optional) Convert resources created with count to for_each, keys being derived with --key",
+		Short:        "",
+		DefaultValue: "false",
+	},
+	ArgKey: {
+		Description:  "(optional) Go template deriving the for_each key of an instance from .attributes, .index, .type, .name and .module - Example: {{ .attributes.name }}",
+		Short:        "k",
+		DefaultValue: "",
+	},
+	ArgToCount: {
+		Description:  "(optional) Convert resources created with for_each, or single resources, to count, instances being numbered following --order",
+		Short:        "",
+		DefaultValue: "false",
+	},
+	ArgOrder: {
+		Description:  "(optional) Order of instances converted to count: key (for_each keys sorted lexically) or state (order of the state file)",
+		Short:        "",
+		DefaultValue: "key",
+	},
+	ArgToSingleton: {
+		Description:  "(optional) Convert resources created with count or for_each holding a single instance to resources without count nor for_each",
+		Short:        "",
+		DefaultValue: "false",
+	},
+	ArgMapping: {
+		Description:  "(optional) YAML or CSV file declaring moves as from (or regex) and to entries - Example: moves.yaml",
+		Short:        "m",
+		DefaultValue: "",
+	},
+	ArgDryRun: {
+		Description:  "(optional) Display the resource instances of the state once moves are applied instead of printing moved directives",
+		Short:        "",
+		DefaultValue: "false",
+	},
+	ArgFormat: {
+		Description:  "(optional) Output format: moved (terraform moved blocks) or state-mv (shell script of terraform state mv commands)",
+		Short:        "",
+		DefaultValue: "moved",
+	},
+	ArgStatePath: {
+		Description:  "(optional) Path of the state file given to terraform state mv with -state (state-mv format)",
+		Short:        "",
+		DefaultValue: "",
+	},
+	ArgStateOutPath: {
+		Description:  "(optional) Path of the state file given to terraform state mv with -state-out, to move resources to another state (state-mv format)",
+		Short:        "",
+		DefaultValue: "",
+	},
+	ArgImportIDs: {
+		Description:  "(optional) YAML file mapping resource types to Go templates of their import ID, extending the built-in table - Example: import-ids.yaml",
+		Short:        "",
+		DefaultValue: "",
+	},
+	ArgImportsOut: {
+		Description:  "(optional) File where the import blocks of removed resources are written, for the configuration receiving them - Example: imports.tf",
+		Short:        "",
+		DefaultValue: "",
+	},
+	ArgRule: {
+		Description:  "(required) Rule name=filter selecting the resources written to the new state name.tfstate, repeatable - Example: network=module.vpc*",
+		Short:        "r",
+		DefaultValue: "",
+	},
+	ArgOutDir: {
+		Description:  "(optional) Directory where new state files, and artifacts, are written",
+		Short:        "o",
+		DefaultValue: ".",
+	},
+	ArgArtifacts: {
+		Description:  "(optional) Also write the removed blocks of each rule for the current configuration and the import blocks for the new ones",
+		Short:        "",
+		DefaultValue: "false",
+	},
+	ArgOut: {
+		Description:  "(required) Path of the merged terraform state",
+		Short:        "o",
+		DefaultValue: "",
+	},
+	ArgInto: {
+		Description:  "(optional) Module where the resources of a state are moved, given once per state in order, empty for the root module, without globs nor wildcard keys - Example: module.a",
+		Short:        "",
+		DefaultValue: "",
+	},
+	ArgPlan: {
+		Description:  "(required) Path of a terraform plan in json, written by terraform show -json",
+		Short:        "",
+		DefaultValue: "",
+	},
+	ArgThreshold: {
+		Description:  "(optional) Minimal confidence, between 0 and 1, of the pairs of deleted and created resources for which moved directives are generated",
+		Short:        "",
+		DefaultValue: "0.75",
+	},
+	ArgShowSensitive: {
+		Description:  "(optional) Display sensitive values instead of redacting them",
+		Short:        "",
+		DefaultValue: "false",
+	},
+	ArgForce: {
+		Description:  "(optional) Replace an existing state file by the new state, which has another lineage",
+		Short:        "",
+		DefaultValue: "false",
+	},
 }
 
 // TerraformStateFilePath is the path where terraform state file can be found
 var TerraformStateFilePath string
 
-// ResourceFilterString is a string representation for a filter
-var ResourceFilterString string
+// ResourceFilterStrings are string representations of filters, a resource matching any of them is selected
+var ResourceFilterStrings []string
+
+// ExcludeFilterStrings are string representations of filters, a resource matching any of them is excluded
+var ExcludeFilterStrings []string
+
+// ShowSensitive tells if sensitive values must be displayed
+var ShowSensitive bool
+
+// RegexString is a regular expression selecting resources to refactor
+var RegexString string
+
+// ProviderFilterString is a provider filter specification
+var ProviderFilterString string
+
+// WhereString is an expression selecting resource instances by attribute values
+var WhereString string
+
+// PerResource tells if moved directives must be generated per resource when a whole module is moved
+var PerResource bool
+
+// ToForEach tells if resources created with count must be converted to for_each
+var ToForEach bool
+
+// KeyTemplate is the template deriving for_each keys from instance attributes
+var KeyTemplate string
+
+// ToCount tells if resources created with for_each must be converted to count
+var ToCount bool
+
+// Order is the order of instances converted to count
+var Order string
+
+// ToSingleton tells if resources holding a single instance must lose their index key
+var ToSingleton bool
+
+// MappingFilePath is the path of a file declaring several moves
+var MappingFilePath string
+
+// DryRun tells if the effect of moves must be displayed instead of moved directives
+var DryRun bool
+
+// Format is the output format of moves
+var Format string
+
+// StatePath is the state file given to terraform state mv with -state
+var StatePath string
+
+// StateOutPath is the state file given to terraform state mv with -state-out
+var StateOutPath string
+
+// ImportIDsFilePath is the path of a file of import ID templates per resource type
+var ImportIDsFilePath string
+
+// ImportsOutPath is the file where import blocks of removed resources are written
+var ImportsOutPath string
+
+// SplitRuleStrings are rules name=filter selecting the resources of each new state
+var SplitRuleStrings []string
+
+// OutDir is the directory where new files are written
+var OutDir string
+
+// Artifacts tells if configuration blocks migrating resources between states must be written
+var Artifacts bool
+
+// OutPath is the path of the state written
+var OutPath string
+
+// IntoModules are the modules where the resources of each merged state are moved
+var IntoModules []string
+
+// PlanFilePath is the path of a terraform plan written by terraform show -json
+var PlanFilePath string
+
+// Threshold is the minimal confidence of suggested moves
+var Threshold float64
+
+// Force tells if an existing state file can be replaced by a new state
+var Force bool
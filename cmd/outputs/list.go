@@ -0,0 +1,74 @@
+// Package outputs create cli commands to manage outputs found in terraform state file
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package outputs
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/ddrugeon/terrafactor/cmd/options"
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+// NewListCommand creates a new `outputs list` command
+func NewListCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "list",
+		Short: "List outputs found in given tfstate",
+		RunE:  listOutputs,
+		Args:  cobra.NoArgs,
+	}
+
+	command.PersistentFlags().StringVarP(&options.TerraformStateFilePath, options.ArgTFStateFile, options.Args[options.ArgTFStateFile].Short, options.Args[options.ArgTFStateFile].DefaultValue, options.Args[options.ArgTFStateFile].Description)
+	err := command.MarkPersistentFlagRequired(options.ArgTFStateFile)
+	if err != nil {
+		return nil
+	}
+	command.PersistentFlags().BoolVar(&options.ShowSensitive, options.ArgShowSensitive, false, options.Args[options.ArgShowSensitive].Description)
+
+	return command
+}
+
+func listOutputs(cmd *cobra.Command, args []string) error {
+	terraformState, err := state.FromFile(options.TerraformStateFilePath)
+	if err != nil {
+		return err
+	}
+
+	panels := pterm.Panels{
+		{{Data: pterm.Yellow("\nTerraform Version:\nProcessed State file:")}, {Data: fmt.Sprintf("\n%s\n%s", terraformState.TerraformVersion, options.TerraformStateFilePath)}},
+	}
+	_ = pterm.DefaultPanel.WithPanels(panels).WithPadding(5).Render()
+
+	table := pterm.TableData{{"Name", "Type", "Sensitive", "Value"}}
+	for _, name := range terraformState.OutputNames() {
+		output := terraformState.Outputs[name]
+		table = append(table, []string{name, output.Type.String(), strconv.FormatBool(output.Sensitive), output.DisplayValue(options.ShowSensitive)})
+	}
+
+	return pterm.DefaultTable.WithHasHeader().WithData(table).Render()
+}
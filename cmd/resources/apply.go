@@ -0,0 +1,88 @@
+// Package resources list cli commands to list all ressources and modules found in terraform state file
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package resources
+
+import (
+	"github.com/ddrugeon/terrafactor/cmd/options"
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+// NewApplyMovesCommand is the command to apply moves directly to a local terraform state file
+func NewApplyMovesCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "apply-moves [flags] old_location [new_location]",
+		Short: "Apply moves directly to a local terraform state file",
+		Long: `Apply moves directly to a local terraform state file
+
+Resources are selected and moved as with the refactor command, but instead of generating
+moved directives, the state file given with --tfstate is rewritten. This is meant for
+terraform versions without moved blocks support.
+
+The serial of the state is incremented and its lineage kept. The previous state is first
+copied next to it with a .backup suffix. The command refuses to run while terraform holds a
+lock on the state, that is when a .terraform.tfstate.lock.info file is found next to it.`,
+		RunE:    applyMoves,
+		Args:    locationArgs,
+		PreRunE: parseLocations,
+	}
+
+	command.PersistentFlags().StringVarP(&options.TerraformStateFilePath, options.ArgTFStateFile, options.Args[options.ArgTFStateFile].Short, options.Args[options.ArgTFStateFile].DefaultValue, options.Args[options.ArgTFStateFile].Description)
+	err := command.MarkPersistentFlagRequired(options.ArgTFStateFile)
+	if err != nil {
+		return nil
+	}
+	addSelectionFlags(command)
+
+	return command
+}
+
+func applyMoves(cmd *cobra.Command, args []string) error {
+	terraformState, err := state.FromFile(options.TerraformStateFilePath)
+	if err != nil {
+		return err
+	}
+
+	moves, err := generateMoves(terraformState)
+	if err != nil {
+		return err
+	}
+	if len(moves) == 0 {
+		pterm.Info.Println("No resource to move, the state is left unchanged")
+		return nil
+	}
+
+	moved, err := terraformState.ApplyMoves(moves)
+	if err != nil {
+		return err
+	}
+	if err := moved.ToFile(options.TerraformStateFilePath); err != nil {
+		return err
+	}
+
+	pterm.Success.Printfln("%d moves applied to %s (serial %d), previous state saved to %s", len(moves), options.TerraformStateFilePath, moved.Serial, options.TerraformStateFilePath+state.BackupSuffix)
+	return nil
+}
@@ -0,0 +1,94 @@
+// Package resources list cli commands to list all ressources and modules found in terraform state file
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package resources
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/pterm/pterm"
+	"github.com/pterm/pterm/putils"
+)
+
+// renderChanges displays the tree of resource instances once moves are applied. Moved
+// instances are shown in green and conflicting ones in red, along with their former address.
+func renderChanges(changes []state.AddressChange) error {
+	sorted := append([]state.AddressChange{}, changes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return lessModule(sorted[i].To.Module, sorted[j].To.Module)
+	})
+
+	counts := map[string]int{}
+	resourcesList := pterm.LeveledList{pterm.LeveledListItem{Level: 0, Text: pterm.Yellow("Resources after moves")}}
+	var previous state.ModulePath
+	for _, change := range sorted {
+		counts[change.Status]++
+
+		module := change.To.Module
+		common := 0
+		for common < len(previous) && common < len(module) && previous[common] == module[common] {
+			common++
+		}
+		for index := common; index < len(module); index++ {
+			resourcesList = append(resourcesList, pterm.LeveledListItem{Level: index + 1, Text: module[index].String()})
+		}
+		previous = module
+
+		leaf := change.To
+		leaf.Module = nil
+		text := leaf.String()
+		switch change.Status {
+		case state.ChangeMoved:
+			text = fmt.Sprintf("%s %s", pterm.Green(text), pterm.Gray("<- ", change.From))
+		case state.ChangeConflicting:
+			if change.From.Equal(change.To) {
+				text = fmt.Sprintf("%s %s", pterm.Red(text), pterm.Gray("(conflicting)"))
+			} else {
+				text = fmt.Sprintf("%s %s", pterm.Red(text), pterm.Gray("<- ", change.From, " (conflicting)"))
+			}
+		}
+		resourcesList = append(resourcesList, pterm.LeveledListItem{Level: len(module) + 1, Text: text})
+	}
+
+	root := putils.TreeFromLeveledList(resourcesList)
+	if err := pterm.DefaultTree.WithRoot(root).Render(); err != nil {
+		return err
+	}
+
+	pterm.Info.Printfln("%d moved, %d untouched, %d conflicting", counts[state.ChangeMoved], counts[state.ChangeUntouched], counts[state.ChangeConflicting])
+	return nil
+}
+
+// lessModule orders module paths step by step, resources of a module coming before those of
+// its nested modules
+func lessModule(left state.ModulePath, right state.ModulePath) bool {
+	for index := 0; index < len(left) && index < len(right); index++ {
+		if left[index] != right[index] {
+			return left[index].String() < right[index].String()
+		}
+	}
+	return len(left) < len(right)
+}
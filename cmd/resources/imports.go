@@ -0,0 +1,96 @@
+// Package resources list cli commands to list all ressources and modules found in terraform state file
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package resources
+
+import (
+	"fmt"
+
+	"github.com/ddrugeon/terrafactor/cmd/options"
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/spf13/cobra"
+)
+
+// NewImportBlocksCommand is the command to generate terraform import blocks
+func NewImportBlocksCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "import-blocks",
+		Short: "Generate terraform import blocks",
+		Long: `Generate terraform import blocks
+
+An import block is generated for each managed resource instance selected with --filter, using
+its id attribute as import ID. Resource types imported with a composite ID, like
+aws_route53_record or aws_iam_role_policy_attachment, use a built-in Go template evaluated on
+the attributes of the instance. The table is extended with --import-ids, a YAML file mapping
+resource types to templates:
+
+  aws_iam_user_group_membership: '{{ .attributes.user }}/{{ index .attributes.groups 0 }}'
+
+Combined with moved directives, import blocks re-home resources into another root module.`,
+		RunE: importBlocks,
+		Args: cobra.NoArgs,
+	}
+
+	command.PersistentFlags().StringVarP(&options.TerraformStateFilePath, options.ArgTFStateFile, options.Args[options.ArgTFStateFile].Short, options.Args[options.ArgTFStateFile].DefaultValue, options.Args[options.ArgTFStateFile].Description)
+	err := command.MarkPersistentFlagRequired(options.ArgTFStateFile)
+	if err != nil {
+		return nil
+	}
+	command.PersistentFlags().StringArrayVarP(&options.ResourceFilterStrings, options.ArgResourceFilter, options.Args[options.ArgResourceFilter].Short, nil, options.Args[options.ArgResourceFilter].Description)
+	command.PersistentFlags().StringArrayVarP(&options.ExcludeFilterStrings, options.ArgExcludeFilter, options.Args[options.ArgExcludeFilter].Short, nil, options.Args[options.ArgExcludeFilter].Description)
+	command.PersistentFlags().StringVarP(&options.WhereString, options.ArgWhere, options.Args[options.ArgWhere].Short, options.Args[options.ArgWhere].DefaultValue, options.Args[options.ArgWhere].Description)
+	command.PersistentFlags().StringVarP(&options.ProviderFilterString, options.ArgProvider, options.Args[options.ArgProvider].Short, options.Args[options.ArgProvider].DefaultValue, options.Args[options.ArgProvider].Description)
+	command.PersistentFlags().StringVar(&options.ImportIDsFilePath, options.ArgImportIDs, options.Args[options.ArgImportIDs].DefaultValue, options.Args[options.ArgImportIDs].Description)
+
+	return command
+}
+
+// stateImports returns the import blocks of the resources selected on the command line
+func stateImports(terraformState *state.TerraformState) ([]state.Import, error) {
+	ids, err := state.LoadImportIDs(options.ImportIDsFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	matcher, err := resourceMatcher(options.ResourceFilterStrings)
+	if err != nil {
+		return nil, err
+	}
+	return terraformState.Imports(matcher, *ids)
+}
+
+func importBlocks(cmd *cobra.Command, args []string) error {
+	terraformState, err := state.FromFile(options.TerraformStateFilePath)
+	if err != nil {
+		return err
+	}
+
+	imports, err := stateImports(terraformState)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(state.FormatImports(imports))
+	return nil
+}
@@ -25,9 +25,7 @@ SOFTWARE.
 package resources
 
 import (
-	"errors"
 	"fmt"
-	"os"
 
 	"github.com/ddrugeon/terrafactor/cmd/options"
 	"github.com/ddrugeon/terrafactor/internal/state"
@@ -41,7 +39,7 @@ func NewListCommand() *cobra.Command {
 	command := &cobra.Command{
 		Use:   "list",
 		Short: "List resources found in given tfstate",
-		Run:   listResources,
+		RunE:  listResources,
 		Args:  cobra.NoArgs,
 	}
 
@@ -50,32 +48,25 @@ func NewListCommand() *cobra.Command {
 	if err != nil {
 		return nil
 	}
-	command.PersistentFlags().StringVarP(&options.ResourceFilterString, options.ArgResourceFilter, options.Args[options.ArgResourceFilter].Short, options.Args[options.ArgResourceFilter].DefaultValue, options.Args[options.ArgResourceFilter].Description)
+	command.PersistentFlags().StringArrayVarP(&options.ResourceFilterStrings, options.ArgResourceFilter, options.Args[options.ArgResourceFilter].Short, nil, options.Args[options.ArgResourceFilter].Description)
+	command.PersistentFlags().StringArrayVarP(&options.ExcludeFilterStrings, options.ArgExcludeFilter, options.Args[options.ArgExcludeFilter].Short, nil, options.Args[options.ArgExcludeFilter].Description)
+	command.PersistentFlags().StringVarP(&options.WhereString, options.ArgWhere, options.Args[options.ArgWhere].Short, options.Args[options.ArgWhere].DefaultValue, options.Args[options.ArgWhere].Description)
+	command.PersistentFlags().StringVarP(&options.ProviderFilterString, options.ArgProvider, options.Args[options.ArgProvider].Short, options.Args[options.ArgProvider].DefaultValue, options.Args[options.ArgProvider].Description)
 
 	return command
 }
 
-func listResources(cmd *cobra.Command, args []string) {
-	filter, err := state.CreateResourceFilterFromString(options.ResourceFilterString)
+func listResources(cmd *cobra.Command, args []string) error {
+	matcher, err := resourceMatcher(options.ResourceFilterStrings)
 	if err != nil {
-		pterm.Error.Println(err)
-		os.Exit(1)
+		return err
 	}
 
-	if _, err := os.Stat(options.TerraformStateFilePath); errors.Is(err, os.ErrNotExist) {
-		pterm.Error.Printf("%s not found\n", options.TerraformStateFilePath)
-		os.Exit(1)
-	}
-
-	file, err := os.Open(options.TerraformStateFilePath)
+	terraformState, err := state.FromFile(options.TerraformStateFilePath)
 	if err != nil {
-		pterm.Error.Printf("Error opening terraform state file %s - %s\n", options.TerraformStateFilePath, err)
-		os.Exit(1)
+		return err
 	}
-	defer file.Close()
-
-	terraformState := state.FromReader(file)
-	terraformResources := terraformState.ListResources(*filter)
+	terraformResources := terraformState.ListResources(matcher)
 
 	panels := pterm.Panels{
 		{{Data: pterm.Yellow("\nTerraform Version:\nProcessed State file:")}, {Data: fmt.Sprintf("\n%s\n%s", terraformState.TerraformVersion, options.TerraformStateFilePath)}},
@@ -84,25 +75,13 @@ func listResources(cmd *cobra.Command, args []string) {
 
 	resourcesList := pterm.LeveledList{pterm.LeveledListItem{Level: 0, Text: pterm.Yellow("Resources")}}
 	for _, resource := range terraformResources {
-		if resource.Mode == "managed" {
-			resourceType := resource.Type
-			resourceName := resource.Name
+		if resource.Mode == state.ManagedMode {
 			for _, instance := range resource.Instances {
-				indexKey := instance.IndexKey
-				resourceLocation := fmt.Sprintf("%s.%s", resourceType, resourceName)
-				if len(resource.Module) > 0 {
-					resourceLocation = fmt.Sprintf("%s.%s", resource.Module, resourceLocation)
-				}
-				if len(indexKey) > 0 {
-					resourceLocation = fmt.Sprintf("%s[\"%s\"]", resourceLocation, indexKey)
-				}
-
-				resourcesList = append(resourcesList, pterm.LeveledListItem{Level: 1, Text: resourceLocation})
-
+				resourcesList = append(resourcesList, pterm.LeveledListItem{Level: 1, Text: resource.Address(instance.IndexKey).String()})
 			}
 		}
 	}
 
 	root := putils.TreeFromLeveledList(resourcesList)
-	_ = pterm.DefaultTree.WithRoot(root).Render()
+	return pterm.DefaultTree.WithRoot(root).Render()
 }
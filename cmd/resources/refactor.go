@@ -31,6 +31,7 @@ import (
 
 	"github.com/ddrugeon/terrafactor/cmd/options"
 	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 )
 
@@ -42,19 +43,54 @@ var (
 // NewRefactorCommand is the command to generate terraform moved directives
 func NewRefactorCommand() *cobra.Command {
 	command := &cobra.Command{
-		Use:   "refactor [flags] old_location new_location",
+		Use:   "refactor [flags] old_location [new_location]",
 		Short: "Generate terraform moved directives",
-		Run:   refactor,
-		Args:  cobra.ExactArgs(2),
-		PreRunE: func(cmd *cobra.Command, args []string) error {
-			if len(args) != 2 {
-				return errors.New("Required arguments old_location or new_location are missing")
-			}
+		Long: `Generate terraform moved directives
+
+Resources are selected either with old_location, which accepts the same syntax as --filter,
+or with a regular expression given with --regex. In the latter case, only new_location is
+expected and it may reference capture groups of the regular expression:
+
+  terrafactor resources refactor -t terraform.tfstate \
+    --regex 'module\.old_(\w+)\.aws_sqs_queue\.(\w+)' 'module.queues["$1"].aws_sqs_queue.${2}'
+
+With --to-for-each, resources created with count are converted to for_each. The key of each
+instance is derived from its attributes with the Go template given with --key. When
+new_location is omitted, resources are kept at their location:
+
+  terrafactor resources refactor -t terraform.tfstate \
+    --to-for-each --key '{{ .attributes.name }}' aws_iam_user.users
+
+Likewise --to-count numbers the instances of resources created with for_each, sorting keys
+unless --order state is given, and --to-singleton removes the key of a resource holding a
+single instance. A single resource becomes [0] with --to-count, or ["default"] with
+--to-for-each --key default.
+
+With --mapping, moves are declared in a YAML or CSV file and no location is expected:
+
+  moves:
+    - from: module.legacy.aws_s3_bucket.logs
+      to: module.storage.aws_s3_bucket.logs
+    - regex: 'module\.old_(\w+)\.aws_sqs_queue\.main'
+      to: 'module.queues["$1"].aws_sqs_queue.main'
+
+Every entry must select at least one resource, each resource must be selected by a single
+entry and each target must be unique.
 
-			oldLocation = args[0]
-			newLocation = args[1]
-			return nil
-		},
+Before any moved directive is printed, moves are rejected when they target an address already
+used in the state, when several resources are moved to the same address or when they form a
+cycle. Chained moves (a -> b -> c) are reported as warnings, and as errors when several
+resources of the state would end at the same address once the chain is followed.
+
+With --dry-run, moves are applied to a copy of the state and the resulting resource instances
+are displayed instead of moved directives, highlighting moved and conflicting instances.
+
+With --format state-mv, a shell script running terraform state mv is generated instead of
+moved directives, for pipelines which can not use them. The script skips moves already
+applied and may be given --state and --state-out to move resources between state files.`,
+		RunE:    refactor,
+		Args:    locationArgs,
+		PreRunE: parseLocations,
 	}
 
 	command.PersistentFlags().StringVarP(&options.TerraformStateFilePath, options.ArgTFStateFile, options.Args[options.ArgTFStateFile].Short, options.Args[options.ArgTFStateFile].DefaultValue, options.Args[options.ArgTFStateFile].Description)
@@ -62,33 +98,218 @@ func NewRefactorCommand() *cobra.Command {
 	if err != nil {
 		return nil
 	}
+	addSelectionFlags(command)
+	command.PersistentFlags().BoolVar(&options.PerResource, options.ArgPerResource, false, options.Args[options.ArgPerResource].Description)
+	command.PersistentFlags().BoolVar(&options.DryRun, options.ArgDryRun, false, options.Args[options.ArgDryRun].Description)
+	command.PersistentFlags().StringVar(&options.Format, options.ArgFormat, options.Args[options.ArgFormat].DefaultValue, options.Args[options.ArgFormat].Description)
+	command.PersistentFlags().StringVar(&options.StatePath, options.ArgStatePath, options.Args[options.ArgStatePath].DefaultValue, options.Args[options.ArgStatePath].Description)
+	command.PersistentFlags().StringVar(&options.StateOutPath, options.ArgStateOutPath, options.Args[options.ArgStateOutPath].DefaultValue, options.Args[options.ArgStateOutPath].Description)
 
 	return command
 }
 
-func refactor(cmd *cobra.Command, args []string) {
-	filter, err := state.CreateResourceFilterFromString(oldLocation)
+// addSelectionFlags adds the flags selecting the resources to move and how they are moved
+func addSelectionFlags(command *cobra.Command) {
+	command.PersistentFlags().StringArrayVarP(&options.ExcludeFilterStrings, options.ArgExcludeFilter, options.Args[options.ArgExcludeFilter].Short, nil, options.Args[options.ArgExcludeFilter].Description)
+	command.PersistentFlags().StringVarP(&options.WhereString, options.ArgWhere, options.Args[options.ArgWhere].Short, options.Args[options.ArgWhere].DefaultValue, options.Args[options.ArgWhere].Description)
+	command.PersistentFlags().StringVarP(&options.ProviderFilterString, options.ArgProvider, options.Args[options.ArgProvider].Short, options.Args[options.ArgProvider].DefaultValue, options.Args[options.ArgProvider].Description)
+	command.PersistentFlags().BoolVar(&options.ToForEach, options.ArgToForEach, false, options.Args[options.ArgToForEach].Description)
+	command.PersistentFlags().StringVarP(&options.KeyTemplate, options.ArgKey, options.Args[options.ArgKey].Short, options.Args[options.ArgKey].DefaultValue, options.Args[options.ArgKey].Description)
+	command.PersistentFlags().BoolVar(&options.ToCount, options.ArgToCount, false, options.Args[options.ArgToCount].Description)
+	command.PersistentFlags().StringVar(&options.Order, options.ArgOrder, options.Args[options.ArgOrder].DefaultValue, options.Args[options.ArgOrder].Description)
+	command.PersistentFlags().BoolVar(&options.ToSingleton, options.ArgToSingleton, false, options.Args[options.ArgToSingleton].Description)
+	command.PersistentFlags().StringVarP(&options.MappingFilePath, options.ArgMapping, options.Args[options.ArgMapping].Short, options.Args[options.ArgMapping].DefaultValue, options.Args[options.ArgMapping].Description)
+	command.PersistentFlags().StringVarP(&options.RegexString, options.ArgRegex, options.Args[options.ArgRegex].Short, options.Args[options.ArgRegex].DefaultValue, options.Args[options.ArgRegex].Description)
+}
+
+// locationArgs checks the number of locations given on the command line
+func locationArgs(cmd *cobra.Command, args []string) error {
+	if options.MappingFilePath != "" {
+		return cobra.NoArgs(cmd, args)
+	}
+	if options.RegexString != "" {
+		return cobra.ExactArgs(1)(cmd, args)
+	}
+	if isConversion() {
+		return cobra.RangeArgs(1, 2)(cmd, args)
+	}
+	return cobra.ExactArgs(2)(cmd, args)
+}
+
+// parseLocations reads old_location and new_location from the command line
+func parseLocations(cmd *cobra.Command, args []string) error {
+	if options.MappingFilePath != "" {
+		if options.RegexString != "" {
+			return fmt.Errorf("--%s and --%s are mutually exclusive", options.ArgMapping, options.ArgRegex)
+		}
+		oldLocation = ""
+		newLocation = ""
+		return nil
+	}
+
+	if options.RegexString != "" {
+		if len(args) != 1 {
+			return errors.New("Required argument new_location is missing")
+		}
+		oldLocation = ""
+		newLocation = args[0]
+		return nil
+	}
+
+	if isConversion() && len(args) == 1 {
+		oldLocation = args[0]
+		newLocation = ""
+		return nil
+	}
+
+	if len(args) != 2 {
+		return errors.New("Required arguments old_location or new_location are missing")
+	}
+
+	oldLocation = args[0]
+	newLocation = args[1]
+	return nil
+}
+
+func newMapping(terraformState state.TerraformState) (state.Mapping, error) {
+	var mapping state.Mapping
+	var err error
+	if options.MappingFilePath != "" {
+		var entries []state.MappingEntry
+		entries, err = state.MappingEntriesFromFile(options.MappingFilePath)
+		if err != nil {
+			return nil, err
+		}
+		var set *state.MappingSet
+		set, err = state.NewMappingSet(entries)
+		if err != nil {
+			return nil, err
+		}
+		if err = set.Validate(terraformState); err != nil {
+			return nil, err
+		}
+		mapping = set
+	} else if options.RegexString != "" {
+		mapping, err = state.NewRegexMapping(options.RegexString, newLocation)
+	} else if newLocation == "" {
+		var filter *state.ResourceFilter
+		filter, err = state.CreateResourceFilterFromString(oldLocation)
+		if filter != nil {
+			mapping = state.InPlaceMapping(filter)
+		}
+	} else {
+		mapping, err = state.NewLocationMapping(oldLocation, newLocation)
+	}
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return nil, err
 	}
 
-	if _, err := os.Stat(options.TerraformStateFilePath); errors.Is(err, os.ErrNotExist) {
-		fmt.Printf("%s not found\n", options.TerraformStateFilePath)
-		os.Exit(1)
+	matcher, err := resourceMatcher(nil)
+	if err != nil {
+		return nil, err
+	}
+	return state.RestrictMapping(mapping, matcher), nil
+}
+
+func isConversion() bool {
+	return options.ToForEach || options.ToCount || options.ToSingleton
+}
+
+// keyConversion returns the conversion of instance keys requested by flags, nil when keys
+// are kept unchanged
+func keyConversion() (state.KeyConversion, error) {
+	conversions := []state.KeyConversion{}
+	if options.ToForEach {
+		if options.KeyTemplate == "" {
+			return nil, fmt.Errorf("--%s is required with --%s", options.ArgKey, options.ArgToForEach)
+		}
+		conversion, err := state.NewForEachConversion(options.KeyTemplate)
+		if err != nil {
+			return nil, err
+		}
+		conversions = append(conversions, conversion)
+	}
+	if options.ToCount {
+		conversion, err := state.NewCountConversion(options.Order)
+		if err != nil {
+			return nil, err
+		}
+		conversions = append(conversions, conversion)
+	}
+	if options.ToSingleton {
+		conversions = append(conversions, state.SingletonConversion{})
+	}
+
+	switch len(conversions) {
+	case 0:
+		return nil, nil
+	case 1:
+		return conversions[0], nil
+	default:
+		return nil, fmt.Errorf("--%s, --%s and --%s are mutually exclusive", options.ArgToForEach, options.ArgToCount, options.ArgToSingleton)
+	}
+}
+
+// generateMoves returns the moves requested on the command line, after checking they can be
+// applied together. Warnings are reported on the standard error.
+func generateMoves(terraformState *state.TerraformState) ([]state.Move, error) {
+	conversion, err := keyConversion()
+	if err != nil {
+		return nil, err
+	}
+
+	mapping, err := newMapping(*terraformState)
+	if err != nil {
+		return nil, err
 	}
 
-	file, err := os.Open(options.TerraformStateFilePath)
+	var moves []state.Move
+	if conversion != nil {
+		moves, err = terraformState.ConvertedMoves(mapping, conversion)
+	} else if options.PerResource {
+		moves, err = terraformState.Moves(mapping)
+	} else {
+		moves, err = terraformState.MovesByModule(mapping)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	warnings, err := terraformState.ValidateMoves(moves)
+	if err != nil {
+		if options.DryRun {
+			if renderErr := renderChanges(terraformState.Changes(moves)); renderErr != nil {
+				return nil, renderErr
+			}
+		}
+		return nil, err
+	}
+	for _, warning := range warnings {
+		pterm.Warning.WithWriter(os.Stderr).Println(warning)
+	}
+	return moves, nil
+}
+
+func refactor(cmd *cobra.Command, args []string) error {
+	formatter, err := state.NewMoveFormatter(options.Format, options.StatePath, options.StateOutPath)
+	if err != nil {
+		return err
+	}
+
+	terraformState, err := state.FromFile(options.TerraformStateFilePath)
 	if err != nil {
-		fmt.Printf("Error opening terraform state file %s - %s\n", options.TerraformStateFilePath, err)
-		os.Exit(1)
+		return err
 	}
-	defer file.Close()
 
-	terraformState := state.FromReader(file)
-	terraformResources := terraformState.ListResources(*filter)
+	moves, err := generateMoves(terraformState)
+	if err != nil {
+		return err
+	}
 
-	for _, resource := range terraformResources {
-		fmt.Println(state.GenerateMovedStatement(resource, newLocation))
+	if options.DryRun {
+		return renderChanges(terraformState.Changes(moves))
 	}
+
+	fmt.Print(formatter.Format(moves))
+	return nil
 }
@@ -0,0 +1,101 @@
+// Package resources list cli commands to list all ressources and modules found in terraform state file
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package resources
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ddrugeon/terrafactor/cmd/options"
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+// NewRemoveCommand is the command to generate terraform removed blocks
+func NewRemoveCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "remove",
+		Short: "Generate terraform removed blocks",
+		Long: `Generate terraform removed blocks
+
+A removed block, keeping the objects with destroy = false, is generated for each managed
+resource selected with --filter, or for each module whose resources are all selected. As
+removed blocks can not designate resource or module instances, every instance of a selected
+resource must be selected.
+
+With --imports-out, the import blocks of the removed resource instances are written to the
+given file, so that the configuration receiving them can import them:
+
+  terrafactor resources remove -t terraform.tfstate -f module.network --imports-out imports.tf`,
+		RunE: remove,
+		Args: cobra.NoArgs,
+	}
+
+	command.PersistentFlags().StringVarP(&options.TerraformStateFilePath, options.ArgTFStateFile, options.Args[options.ArgTFStateFile].Short, options.Args[options.ArgTFStateFile].DefaultValue, options.Args[options.ArgTFStateFile].Description)
+	err := command.MarkPersistentFlagRequired(options.ArgTFStateFile)
+	if err != nil {
+		return nil
+	}
+	command.PersistentFlags().StringArrayVarP(&options.ResourceFilterStrings, options.ArgResourceFilter, options.Args[options.ArgResourceFilter].Short, nil, options.Args[options.ArgResourceFilter].Description)
+	command.PersistentFlags().StringArrayVarP(&options.ExcludeFilterStrings, options.ArgExcludeFilter, options.Args[options.ArgExcludeFilter].Short, nil, options.Args[options.ArgExcludeFilter].Description)
+	command.PersistentFlags().StringVarP(&options.WhereString, options.ArgWhere, options.Args[options.ArgWhere].Short, options.Args[options.ArgWhere].DefaultValue, options.Args[options.ArgWhere].Description)
+	command.PersistentFlags().StringVarP(&options.ProviderFilterString, options.ArgProvider, options.Args[options.ArgProvider].Short, options.Args[options.ArgProvider].DefaultValue, options.Args[options.ArgProvider].Description)
+	command.PersistentFlags().StringVar(&options.ImportsOutPath, options.ArgImportsOut, options.Args[options.ArgImportsOut].DefaultValue, options.Args[options.ArgImportsOut].Description)
+	command.PersistentFlags().StringVar(&options.ImportIDsFilePath, options.ArgImportIDs, options.Args[options.ArgImportIDs].DefaultValue, options.Args[options.ArgImportIDs].Description)
+
+	return command
+}
+
+func remove(cmd *cobra.Command, args []string) error {
+	matcher, err := resourceMatcher(options.ResourceFilterStrings)
+	if err != nil {
+		return err
+	}
+
+	terraformState, err := state.FromFile(options.TerraformStateFilePath)
+	if err != nil {
+		return err
+	}
+
+	removals, err := terraformState.Removals(matcher)
+	if err != nil {
+		return err
+	}
+
+	if options.ImportsOutPath != "" {
+		imports, err := stateImports(terraformState)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(options.ImportsOutPath, []byte(state.FormatImports(imports)), 0o644); err != nil {
+			return err
+		}
+		pterm.Info.WithWriter(os.Stderr).Printfln("%d import blocks written to %s", len(imports), options.ImportsOutPath)
+	}
+
+	fmt.Print(state.FormatRemovals(removals))
+	return nil
+}
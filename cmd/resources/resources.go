@@ -25,6 +25,10 @@ SOFTWARE.
 package resources
 
 import (
+	"strings"
+
+	"github.com/ddrugeon/terrafactor/cmd/options"
+	"github.com/ddrugeon/terrafactor/internal/state"
 	"github.com/spf13/cobra"
 )
 
@@ -43,5 +47,48 @@ func NewResourceCommand() *cobra.Command {
 
 	command.AddCommand(NewListCommand())
 	command.AddCommand(NewRefactorCommand())
+	command.AddCommand(NewApplyMovesCommand())
+	command.AddCommand(NewImportBlocksCommand())
+	command.AddCommand(NewRemoveCommand())
+	command.AddCommand(NewSuggestCommand())
 	return command
 }
+
+// providerFilter validates the provider filter given on the command line
+func providerFilter() (string, error) {
+	provider := strings.TrimSpace(options.ProviderFilterString)
+	if provider == "" {
+		return "", nil
+	}
+	if _, err := state.ParseProviderAddress(provider); err == nil {
+		return provider, nil
+	}
+	if _, err := state.ParseProviderFilter(provider); err != nil {
+		return "", err
+	}
+	return provider, nil
+}
+
+// resourceMatcher combines the filters, excluded filters, provider and where expression given
+// on the command line
+func resourceMatcher(includes []string) (state.Matcher, error) {
+	filters, err := state.NewFilterSet(includes, options.ExcludeFilterStrings)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := providerFilter()
+	if err != nil {
+		return nil, err
+	}
+	matchers := []state.Matcher{filters, state.ResourceFilter{Provider: provider}}
+
+	if strings.TrimSpace(options.WhereString) != "" {
+		where, err := state.ParseWhere(options.WhereString)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, where)
+	}
+	return state.MatchAll(matchers...), nil
+}
@@ -0,0 +1,149 @@
+// Package resources list cli commands to list all ressources and modules found in terraform state file
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package resources
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/ddrugeon/terrafactor/cmd/options"
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+// NewSuggestCommand is the command to suggest terraform moved directives from a plan
+func NewSuggestCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "suggest",
+		Short: "Suggest terraform moved directives from a plan",
+		Long: `Suggest terraform moved directives from a plan
+
+When resources are renamed without moved directives, terraform plans to delete them and to
+create new ones. Each resource instance of the state planned for deletion is paired with a
+resource instance of the same type planned for creation, from the attributes having the same
+value on both sides. Names and tags count more than references to other objects (*_id, *_arn),
+which count more than other attributes.
+
+  terraform plan -out plan.out && terraform show -json plan.out > plan.json
+  terrafactor resources suggest -t terraform.tfstate --plan plan.json
+
+Candidates are displayed on the standard error, ranked by confidence and followed by the best
+alternatives of each deleted instance, and moved directives are printed for those reaching
+--threshold.`,
+		RunE: suggest,
+		Args: cobra.NoArgs,
+	}
+
+	command.PersistentFlags().StringVarP(&options.TerraformStateFilePath, options.ArgTFStateFile, options.Args[options.ArgTFStateFile].Short, options.Args[options.ArgTFStateFile].DefaultValue, options.Args[options.ArgTFStateFile].Description)
+	err := command.MarkPersistentFlagRequired(options.ArgTFStateFile)
+	if err != nil {
+		return nil
+	}
+	command.PersistentFlags().StringVar(&options.PlanFilePath, options.ArgPlan, options.Args[options.ArgPlan].DefaultValue, options.Args[options.ArgPlan].Description)
+	err = command.MarkPersistentFlagRequired(options.ArgPlan)
+	if err != nil {
+		return nil
+	}
+	threshold, err := strconv.ParseFloat(options.Args[options.ArgThreshold].DefaultValue, 64)
+	if err != nil {
+		return nil
+	}
+	command.PersistentFlags().Float64Var(&options.Threshold, options.ArgThreshold, threshold, options.Args[options.ArgThreshold].Description)
+
+	return command
+}
+
+// displayedAlternatives is the maximum number of alternatives displayed for a deleted instance
+const displayedAlternatives = 2
+
+// renderSuggestions displays the suggestions on the standard error, each followed by its best
+// alternatives
+func renderSuggestions(suggestions []state.Suggestion) error {
+	data := pterm.TableData{{"From", "To", "Confidence"}}
+	for _, suggestion := range suggestions {
+		confidence := fmt.Sprintf("%.0f%%", suggestion.Confidence*100)
+		if suggestion.Confidence >= options.Threshold {
+			confidence = pterm.Green(confidence)
+		} else {
+			confidence = pterm.Gray(confidence)
+		}
+		data = append(data, []string{suggestion.Move.From.String(), suggestion.Move.To.String(), confidence})
+
+		for index, alternative := range suggestion.Alternatives {
+			if index == displayedAlternatives {
+				data = append(data, []string{"", pterm.Gray(fmt.Sprintf("%d more", len(suggestion.Alternatives)-index)), ""})
+				break
+			}
+			data = append(data, []string{"", pterm.Gray(alternative.Move.To.String()), pterm.Gray(fmt.Sprintf("%.0f%%", alternative.Confidence*100))})
+		}
+	}
+	return pterm.DefaultTable.WithHasHeader().WithData(data).WithWriter(os.Stderr).Render()
+}
+
+func suggest(cmd *cobra.Command, args []string) error {
+	if options.Threshold < 0 || options.Threshold > 1 {
+		return fmt.Errorf("--%s must be between 0 and 1", options.ArgThreshold)
+	}
+
+	terraformState, err := state.FromFile(options.TerraformStateFilePath)
+	if err != nil {
+		return err
+	}
+	plan, err := state.PlanFromFile(options.PlanFilePath)
+	if err != nil {
+		return err
+	}
+
+	suggestions, err := plan.Suggestions(*terraformState)
+	if err != nil {
+		return err
+	}
+	if len(suggestions) == 0 {
+		pterm.Info.WithWriter(os.Stderr).Println("No resource planned for deletion matches a resource planned for creation")
+		return nil
+	}
+	if err := renderSuggestions(suggestions); err != nil {
+		return err
+	}
+
+	moves := []state.Move{}
+	for _, suggestion := range suggestions {
+		if suggestion.Confidence >= options.Threshold {
+			moves = append(moves, suggestion.Move)
+		}
+	}
+	warnings, err := terraformState.ValidateMoves(moves)
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		pterm.Warning.WithWriter(os.Stderr).Println(warning)
+	}
+
+	fmt.Print(state.MovedBlocks{}.Format(moves))
+	return nil
+}
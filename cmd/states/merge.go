@@ -0,0 +1,117 @@
+// Package states create cli commands to split and merge terraform state files
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package states
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ddrugeon/terrafactor/cmd/options"
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+// NewMergeCommand creates a new `state merge` command
+func NewMergeCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "merge [flags] state state...",
+		Short: "Merge several terraform states into one",
+		Long: `Merge several terraform states into one
+
+The resources of the given states are written to a new state with a fresh lineage and serial 1.
+With --into, given once per state in order, the resources of each state are moved to a module,
+the root module being kept for an empty value:
+
+  terrafactor state merge a.tfstate b.tfstate -o merged.tfstate --into module.a --into module.b
+
+The merge fails when a managed resource address is found in several states, or when managed
+resources of the same type have the same id. Data sources found in several states, such as
+data.aws_region.current, are read again by terraform on every plan and are taken from the
+first state. Outputs found with different values in several states, and outputs of states
+moved to a module, are reported and not merged.
+
+The merged state is a new state with its own lineage: an existing file at the --out path is
+only replaced with --force.`,
+		RunE: merge,
+		Args: cobra.MinimumNArgs(2),
+	}
+
+	command.PersistentFlags().StringVarP(&options.OutPath, options.ArgOut, options.Args[options.ArgOut].Short, options.Args[options.ArgOut].DefaultValue, options.Args[options.ArgOut].Description)
+	err := command.MarkPersistentFlagRequired(options.ArgOut)
+	if err != nil {
+		return nil
+	}
+	command.PersistentFlags().StringArrayVar(&options.IntoModules, options.ArgInto, nil, options.Args[options.ArgInto].Description)
+	command.PersistentFlags().BoolVar(&options.Force, options.ArgForce, false, options.Args[options.ArgForce].Description)
+
+	return command
+}
+
+func merge(cmd *cobra.Command, args []string) error {
+	if len(options.IntoModules) > 0 && len(options.IntoModules) != len(args) {
+		return fmt.Errorf("--%s must be given once per state, %d given for %d states", options.ArgInto, len(options.IntoModules), len(args))
+	}
+
+	if _, err := os.Stat(options.OutPath); err == nil && !options.Force {
+		return fmt.Errorf("%s already exists and would get a new lineage, use --%s to replace it", options.OutPath, options.ArgForce)
+	}
+
+	output, _ := filepath.Abs(options.OutPath)
+	sources := make([]state.MergeSource, 0, len(args))
+	for index, path := range args {
+		if source, _ := filepath.Abs(path); source == output {
+			return fmt.Errorf("%s would overwrite a merged state, use another --%s", options.OutPath, options.ArgOut)
+		}
+
+		terraformState, err := state.FromFile(path)
+		if err != nil {
+			return err
+		}
+		source := state.MergeSource{Name: path, State: terraformState}
+		if len(options.IntoModules) > 0 && options.IntoModules[index] != "" {
+			source.Into, err = state.ParseModuleAddress(options.IntoModules[index])
+			if err != nil {
+				return fmt.Errorf("--%s %q: %w", options.ArgInto, options.IntoModules[index], err)
+			}
+		}
+		sources = append(sources, source)
+	}
+
+	merged, warnings, err := state.Merge(sources)
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		pterm.Warning.WithWriter(os.Stderr).Println(warning)
+	}
+
+	if err := merged.ToFile(options.OutPath); err != nil {
+		return err
+	}
+	pterm.Success.Printfln("%d resources of %d states written to %s", len(merged.Resources), len(sources), options.OutPath)
+	return nil
+}
@@ -0,0 +1,192 @@
+// Package states create cli commands to split and merge terraform state files
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package states
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ddrugeon/terrafactor/cmd/options"
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+// NewSplitCommand creates a new `state split` command
+func NewSplitCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "split",
+		Short: "Split a terraform state into several states",
+		Long: `Split a terraform state into several states
+
+Each --rule name=filter selects resources, with the syntax of --filter, which are written to
+a new state name.tfstate with a fresh lineage and serial 1. Resources matched by no rule are
+written to remainder.tfstate, a new version of the state keeping its lineage and outputs.
+Resources keep their address, so new configurations must declare them at the same location.
+No moved directive is generated: addresses do not change and moved directives can not cross
+state boundaries, use resources refactor on the new states to relocate resources afterwards.
+
+  terrafactor state split -t big.tfstate --rule 'network=module.vpc*' --rule 'data=module.rds*'
+
+Only state files are written by default. With --artifacts, name.removed.tf also holds the
+removed blocks of the resources to drop from the current configuration and name.imports.tf
+the import blocks of the new configuration, to migrate with terraform instead of pushing the
+state files. No artifact is written for the remainder, which keeps the current configuration.`,
+		RunE: split,
+		Args: cobra.NoArgs,
+	}
+
+	command.PersistentFlags().StringVarP(&options.TerraformStateFilePath, options.ArgTFStateFile, options.Args[options.ArgTFStateFile].Short, options.Args[options.ArgTFStateFile].DefaultValue, options.Args[options.ArgTFStateFile].Description)
+	err := command.MarkPersistentFlagRequired(options.ArgTFStateFile)
+	if err != nil {
+		return nil
+	}
+	command.PersistentFlags().StringArrayVarP(&options.SplitRuleStrings, options.ArgRule, options.Args[options.ArgRule].Short, nil, options.Args[options.ArgRule].Description)
+	err = command.MarkPersistentFlagRequired(options.ArgRule)
+	if err != nil {
+		return nil
+	}
+	command.PersistentFlags().StringVarP(&options.OutDir, options.ArgOutDir, options.Args[options.ArgOutDir].Short, options.Args[options.ArgOutDir].DefaultValue, options.Args[options.ArgOutDir].Description)
+	command.PersistentFlags().BoolVar(&options.Artifacts, options.ArgArtifacts, false, options.Args[options.ArgArtifacts].Description)
+	command.PersistentFlags().StringVar(&options.ImportIDsFilePath, options.ArgImportIDs, options.Args[options.ArgImportIDs].DefaultValue, options.Args[options.ArgImportIDs].Description)
+
+	return command
+}
+
+// splitFiles returns the content of the files written for each part of the split, by path
+func splitFiles(terraformState *state.TerraformState, parts []state.SplitPart) (map[string]string, error) {
+	files := map[string]string{}
+	if !options.Artifacts {
+		return files, nil
+	}
+
+	ids, err := state.LoadImportIDs(options.ImportIDsFilePath)
+	if err != nil {
+		return nil, err
+	}
+	for _, part := range parts {
+		removals, err := terraformState.Removals(part.Rule)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", part.Rule.Name, err)
+		}
+		imports, err := part.State.Imports(state.MatchAll(), *ids)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", part.Rule.Name, err)
+		}
+		files[filepath.Join(options.OutDir, part.Rule.Name+".removed.tf")] = state.FormatRemovals(removals)
+		files[filepath.Join(options.OutDir, part.Rule.Name+".imports.tf")] = state.FormatImports(imports)
+	}
+	return files, nil
+}
+
+func split(cmd *cobra.Command, args []string) error {
+	rules := make([]state.SplitRule, 0, len(options.SplitRuleStrings))
+	for _, value := range options.SplitRuleStrings {
+		rule, err := state.ParseSplitRule(value)
+		if err != nil {
+			return err
+		}
+		rules = append(rules, *rule)
+	}
+
+	terraformState, err := state.FromFile(options.TerraformStateFilePath)
+	if err != nil {
+		return err
+	}
+
+	parts, remainder, err := terraformState.Split(rules)
+	if err != nil {
+		return err
+	}
+	files, err := splitFiles(terraformState, parts)
+	if err != nil {
+		return err
+	}
+
+	states := map[string]*state.TerraformState{filepath.Join(options.OutDir, state.RemainderName+".tfstate"): remainder}
+	for _, part := range parts {
+		states[filepath.Join(options.OutDir, part.Rule.Name+".tfstate")] = part.State
+	}
+	if err := checkTargets(states, files); err != nil {
+		return err
+	}
+
+	for _, path := range sortedPaths(states) {
+		if err := states[path].ToFile(path); err != nil {
+			return err
+		}
+		pterm.Success.Printfln("%d resources written to %s", len(states[path].Resources), path)
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return err
+		}
+	}
+	if len(files) > 0 {
+		pterm.Info.Printfln("removed and import blocks written to %s", options.OutDir)
+	}
+	return nil
+}
+
+// checkTargets makes sure every state and artifact of the split can be written before any of
+// them is, so that a failure does not leave a partial split behind
+func checkTargets(states map[string]*state.TerraformState, files map[string]string) error {
+	source, _ := filepath.Abs(options.TerraformStateFilePath)
+	for path := range states {
+		if target, _ := filepath.Abs(path); target == source {
+			return fmt.Errorf("%s would overwrite the state being split, use another --%s", path, options.ArgOutDir)
+		}
+		if err := state.CheckWritable(path); err != nil {
+			return err
+		}
+	}
+	for path := range files {
+		if info, err := os.Stat(path); err == nil && !info.Mode().IsRegular() {
+			return fmt.Errorf("%s is not a regular file", path)
+		}
+	}
+
+	if err := os.MkdirAll(options.OutDir, 0o755); err != nil {
+		return err
+	}
+	probe, err := os.CreateTemp(options.OutDir, ".terrafactor.*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable - %w", options.OutDir, err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+// sortedPaths returns the paths of the states to write in lexical order
+func sortedPaths(states map[string]*state.TerraformState) []string {
+	paths := make([]string, 0, len(states))
+	for path := range states {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -25,13 +25,17 @@ SOFTWARE.
 package cmd
 
 import (
-	"fmt"
+	"errors"
 	"os"
 	"strings"
 
 	"github.com/ddrugeon/terrafactor/cmd/options"
+	"github.com/ddrugeon/terrafactor/cmd/outputs"
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/pterm/pterm"
 
 	"github.com/ddrugeon/terrafactor/cmd/resources"
+	"github.com/ddrugeon/terrafactor/cmd/states"
 	"github.com/spf13/viper"
 
 	"github.com/spf13/cobra"
@@ -41,6 +45,16 @@ const (
 	envPrefix = "TFCT"
 )
 
+// Exit codes returned by terrafactor
+const (
+	// ExitCodeError is returned when a command fails
+	ExitCodeError = 1
+	// ExitCodeInvalidState is returned when terraform state file can not be found or decoded
+	ExitCodeInvalidState = 2
+	// ExitCodeUnsupportedState is returned when terraform state format version is not supported
+	ExitCodeUnsupportedState = 3
+)
+
 // NewRootCommand builds the main cli application and
 // adds children command hierarchy
 func NewRootCommand() *cobra.Command {
@@ -48,10 +62,15 @@ func NewRootCommand() *cobra.Command {
 		Use:   options.ApplicationName,
 		Short: options.ApplicationShort,
 		Long:  options.ApplicationLong,
+
+		SilenceErrors: true,
+		SilenceUsage:  true,
 	}
 
 	command.AddCommand(
 		resources.NewResourceCommand(),
+		outputs.NewOutputCommand(),
+		states.NewStateCommand(),
 		NewVersionCommand(),
 	)
 
@@ -65,8 +84,22 @@ func Execute() {
 	cmd := NewRootCommand()
 
 	if err := cmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		pterm.Error.Println(err)
+		os.Exit(ExitCode(err))
+	}
+}
+
+// ExitCode returns the process exit code matching an error returned by a command
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, state.ErrUnsupportedStateVersion):
+		return ExitCodeUnsupportedState
+	case errors.Is(err, state.ErrMalformedState), errors.Is(err, state.ErrStateNotFound):
+		return ExitCodeInvalidState
+	default:
+		return ExitCodeError
 	}
 }
 
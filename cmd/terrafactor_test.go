@@ -24,10 +24,13 @@ SOFTWARE.
 package cmd_test
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/ddrugeon/terrafactor/cmd"
 	"github.com/ddrugeon/terrafactor/cmd/options"
+	"github.com/ddrugeon/terrafactor/internal/state"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 )
@@ -41,3 +44,11 @@ func TestNewRootCommand(t *testing.T) {
 	assert.Equal(t, options.ApplicationShort, cmd.Short)
 	assert.Equal(t, options.ApplicationLong, cmd.Long)
 }
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, 0, cmd.ExitCode(nil))
+	assert.Equal(t, cmd.ExitCodeError, cmd.ExitCode(errors.New("failure")))
+	assert.Equal(t, cmd.ExitCodeInvalidState, cmd.ExitCode(fmt.Errorf("file.tfstate: %w", state.ErrMalformedState)))
+	assert.Equal(t, cmd.ExitCodeInvalidState, cmd.ExitCode(state.ErrStateNotFound))
+	assert.Equal(t, cmd.ExitCodeUnsupportedState, cmd.ExitCode(fmt.Errorf("file.tfstate: %w", state.ErrUnsupportedStateVersion)))
+}
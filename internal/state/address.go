@@ -0,0 +1,268 @@
+// Package state list all related resource to terraform state file
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Resource modes found in terraform state
+const (
+	ManagedMode = "managed"
+	DataMode    = "data"
+)
+
+// Address is the address of a resource, or of one of its instances when Key is set,
+// for instance module.app["eu"].aws_s3_bucket.logs or data.aws_vpc.main[0]. An address
+// without Type designates the module instance Module, see ModuleAddress.
+type Address struct {
+	Module ModulePath
+	Mode   string
+	Type   string
+	Name   string
+	Key    IndexKey
+}
+
+// ModuleAddress returns the address designating a whole module instance
+func ModuleAddress(module ModulePath) Address {
+	return Address{Module: module}
+}
+
+// IsModule returns true if the address designates a whole module instance
+func (a Address) IsModule() bool {
+	return a.Type == ""
+}
+
+// String renders the canonical form of the address
+func (a Address) String() string {
+	if a.IsModule() {
+		return a.Module.String()
+	}
+
+	var builder strings.Builder
+	if !a.Module.IsRoot() {
+		builder.WriteString(a.Module.String())
+		builder.WriteString(".")
+	}
+	if a.Mode == DataMode {
+		builder.WriteString("data.")
+	}
+	builder.WriteString(a.Type)
+	builder.WriteString(".")
+	builder.WriteString(a.Name)
+	builder.WriteString(a.Key.String())
+	return builder.String()
+}
+
+// Resource returns the address of the resource without its instance key
+func (a Address) Resource() Address {
+	a.Key = NoKey
+	return a
+}
+
+// WithKey returns the address of the resource instance identified by key
+func (a Address) WithKey(key IndexKey) Address {
+	a.Key = key
+	return a
+}
+
+// Equal returns true if both addresses designate the same resource instance
+func (a Address) Equal(other Address) bool {
+	return a.String() == other.String()
+}
+
+// ParseAddress parses a resource (or resource instance) address. Module and resource
+// names must be valid terraform identifiers and wildcards are not allowed.
+func ParseAddress(input string) (Address, error) {
+	steps, err := parseStrictTraversal(input)
+	if err != nil {
+		return Address{}, err
+	}
+
+	module, steps, err := parseModuleSteps(steps)
+	if err != nil {
+		return Address{}, fmt.Errorf("invalid address %q: %w", input, err)
+	}
+
+	address := Address{Module: module, Mode: ManagedMode}
+	if len(steps) == 3 && steps[0].name == DataMode && steps[0].key.IsNone() {
+		address.Mode = DataMode
+		steps = steps[1:]
+	}
+	if len(steps) != 2 || !steps[0].key.IsNone() || steps[0].name == DataMode {
+		return Address{}, fmt.Errorf("invalid address %q: expected [module.name.]type.name[key]", input)
+	}
+
+	address.Type = steps[0].name
+	address.Name = steps[1].name
+	address.Key = steps[1].key
+	return address, nil
+}
+
+// ParseModuleAddress parses a module instance address such as module.app["eu"].module.db.
+// Module names must be valid terraform identifiers and wildcards are not allowed.
+func ParseModuleAddress(input string) (ModulePath, error) {
+	steps, err := parseStrictTraversal(input)
+	if err != nil {
+		return nil, err
+	}
+
+	module, steps, err := parseModuleSteps(steps)
+	if err != nil {
+		return nil, fmt.Errorf("invalid module address %q: %w", input, err)
+	}
+	if module.IsRoot() || len(steps) > 0 {
+		return nil, fmt.Errorf("invalid module address %q: expected module.name[.module.name]", input)
+	}
+	return module, nil
+}
+
+func parseStrictTraversal(input string) ([]traversalStep, error) {
+	steps, err := parseTraversal(input)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q: %w", input, err)
+	}
+
+	for _, step := range steps {
+		if !isIdentifier(step.name) {
+			return nil, fmt.Errorf("invalid address %q: %q is not a valid name", input, step.name)
+		}
+		if step.key.IsAny() {
+			return nil, fmt.Errorf("invalid address %q: wildcard keys are not allowed", input)
+		}
+	}
+	return steps, nil
+}
+
+// isIdentifier returns true if value is a valid terraform identifier
+func isIdentifier(value string) bool {
+	for index, char := range value {
+		if unicode.IsLetter(char) || char == '_' {
+			continue
+		}
+		if index > 0 && (unicode.IsDigit(char) || char == '-') {
+			continue
+		}
+		return false
+	}
+	return value != ""
+}
+
+// quoteString renders value as a terraform quoted string, escaping the characters that
+// would otherwise be interpreted as escape sequences or template directives.
+func quoteString(value string) string {
+	var builder strings.Builder
+	builder.WriteByte('"')
+	for index, char := range value {
+		switch {
+		case char == '\\':
+			builder.WriteString(`\\`)
+		case char == '"':
+			builder.WriteString(`\"`)
+		case char == '\n':
+			builder.WriteString(`\n`)
+		case char == '\r':
+			builder.WriteString(`\r`)
+		case char == '\t':
+			builder.WriteString(`\t`)
+		case (char == '$' || char == '%') && strings.HasPrefix(value[index+1:], "{"):
+			builder.WriteRune(char)
+			builder.WriteRune(char)
+		case !unicode.IsPrint(char):
+			if char > 0xffff {
+				fmt.Fprintf(&builder, `\U%08x`, char)
+			} else {
+				fmt.Fprintf(&builder, `\u%04x`, char)
+			}
+		default:
+			builder.WriteRune(char)
+		}
+	}
+	builder.WriteByte('"')
+	return builder.String()
+}
+
+// unquoteString decodes a terraform quoted string rendered by quoteString
+func unquoteString(quoted string) (string, error) {
+	if len(quoted) < 2 || quoted[0] != '"' || quoted[len(quoted)-1] != '"' {
+		return "", errors.New("string must be enclosed in double quotes")
+	}
+
+	value := quoted[1 : len(quoted)-1]
+	var builder strings.Builder
+	for index := 0; index < len(value); {
+		char, size := utf8.DecodeRuneInString(value[index:])
+		switch {
+		case char == '"':
+			return "", errors.New("unescaped double quote in string")
+		case (char == '$' || char == '%') && strings.HasPrefix(value[index+1:], string(char)+"{"):
+			builder.WriteRune(char)
+			index += 2
+		case char == '\\':
+			if index+1 >= len(value) {
+				return "", errors.New("unterminated escape sequence")
+			}
+			switch value[index+1] {
+			case '\\', '"':
+				builder.WriteByte(value[index+1])
+				index += 2
+			case 'n':
+				builder.WriteByte('\n')
+				index += 2
+			case 'r':
+				builder.WriteByte('\r')
+				index += 2
+			case 't':
+				builder.WriteByte('\t')
+				index += 2
+			case 'u', 'U':
+				length := 4
+				if value[index+1] == 'U' {
+					length = 8
+				}
+				if index+2+length > len(value) {
+					return "", errors.New("invalid unicode escape sequence")
+				}
+				code, err := strconv.ParseUint(value[index+2:index+2+length], 16, 32)
+				if err != nil {
+					return "", errors.New("invalid unicode escape sequence")
+				}
+				builder.WriteRune(rune(code))
+				index += 2 + length
+			default:
+				return "", fmt.Errorf("invalid escape sequence \\%c", value[index+1])
+			}
+		default:
+			builder.WriteRune(char)
+			index += size
+		}
+	}
+	return builder.String(), nil
+}
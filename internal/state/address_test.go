@@ -0,0 +1,120 @@
+/*
+MIT License
+
+# Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state_test
+
+import (
+	"testing"
+
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAddress(t *testing.T) {
+	t.Run("Valid addresses should be parsed", func(t *testing.T) {
+		address, err := state.ParseAddress(`module.app["eu"].module.db[0].data.aws_db_instance.main[1]`)
+		assert.Nil(t, err)
+		assert.Equal(t, state.Address{
+			Module: state.ModulePath{
+				{Name: "app", Key: state.StringKey("eu")},
+				{Name: "db", Key: state.IntKey(0)},
+			},
+			Mode: state.DataMode,
+			Type: "aws_db_instance",
+			Name: "main",
+			Key:  state.IntKey(1),
+		}, address)
+
+		address, err = state.ParseAddress("aws_instance.web")
+		assert.Nil(t, err)
+		assert.Equal(t, state.Address{Module: state.ModulePath{}, Mode: state.ManagedMode, Type: "aws_instance", Name: "web"}, address)
+	})
+
+	t.Run("Invalid addresses should returns an error", func(t *testing.T) {
+		for _, input := range []string{
+			"",
+			"aws_instance",
+			"aws_instance.web.id",
+			"aws_instance[0].web",
+			"module.app",
+			"module.app[*].aws_instance.web",
+			"aws_instance.web[*]",
+			"aws_instance.1web",
+			"aws instance.web",
+			"aws_*.web",
+			`aws_instance.web["a]`,
+			`aws_instance.web["\q"]`,
+			"data.aws_instance",
+		} {
+			_, err := state.ParseAddress(input)
+			assert.NotNil(t, err, input)
+		}
+	})
+}
+
+func TestAddress_String(t *testing.T) {
+	t.Run("Addresses should round trip", func(t *testing.T) {
+		for _, input := range []string{
+			"aws_instance.web",
+			"aws_instance.web[0]",
+			`aws_instance.web["a"]`,
+			"data.aws_vpc.main",
+			`module.app["eu.west-1"].module.db[2].aws_db_instance.main["primary"]`,
+			`aws_instance.web["quote \" and \\ backslash"]`,
+			`aws_instance.web["line\nbreak\ttab"]`,
+			`aws_instance.web["$${not_a_template} %%{nor_a_directive}"]`,
+			`aws_instance.web["déjà vu"]`,
+		} {
+			address, err := state.ParseAddress(input)
+			assert.Nil(t, err, input)
+			assert.Equal(t, input, address.String())
+		}
+	})
+
+	t.Run("String keys should be escaped", func(t *testing.T) {
+		address := state.Address{Mode: state.ManagedMode, Type: "aws_instance", Name: "web", Key: state.StringKey("a\"b\\c${d}\x01")}
+		assert.Equal(t, `aws_instance.web["a\"b\\c$${d}\u0001"]`, address.String())
+
+		parsed, err := state.ParseAddress(address.String())
+		assert.Nil(t, err)
+		assert.Equal(t, address.Key, parsed.Key)
+	})
+}
+
+func TestParseModuleAddress(t *testing.T) {
+	module, err := state.ParseModuleAddress(`module.app["eu"].module.db`)
+	assert.Nil(t, err)
+	assert.Equal(t, state.ModulePath{{Name: "app", Key: state.StringKey("eu")}, {Name: "db"}}, module)
+
+	for _, input := range []string{"", "module", "module.app.aws_instance.web", "module.app[*]", "module.app-*"} {
+		_, err := state.ParseModuleAddress(input)
+		assert.NotNil(t, err, input)
+	}
+}
+
+func TestTerraformResource_Address(t *testing.T) {
+	resource := state.TerraformResource{Module: `module.app["eu"]`, Mode: state.DataMode, Type: "aws_vpc", Name: "main"}
+
+	assert.Equal(t, `module.app["eu"].data.aws_vpc.main`, resource.String())
+	assert.Equal(t, `module.app["eu"].data.aws_vpc.main[0]`, resource.Address(state.IntKey(0)).String())
+}
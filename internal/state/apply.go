@@ -0,0 +1,221 @@
+// Package state list all related resource to terraform state file
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Statuses of resource instances once moves are applied
+const (
+	// ChangeMoved is the status of a resource instance relocated by a move
+	ChangeMoved = "moved"
+	// ChangeUntouched is the status of a resource instance not affected by any move
+	ChangeUntouched = "untouched"
+	// ChangeConflicting is the status of a resource instance whose new address is also used
+	// by another instance, or which is moved in a cycle
+	ChangeConflicting = "conflicting"
+)
+
+// AddressChange describes the address of a resource instance before and after moves are applied
+type AddressChange struct {
+	From   Address
+	To     Address
+	Status string
+	// deposed is the key of the deposed object of the instance
+	deposed string
+}
+
+// Changes returns the address of every resource instance of the state before and after moves
+// are applied, in the order of the state
+func (s TerraformState) Changes(moves []Move) []AddressChange {
+	changes := []AddressChange{}
+	targets := map[string][]int{}
+	keyKinds := map[string]map[indexKeyKind]bool{}
+
+	for _, resource := range s.Resources {
+		for _, instance := range resource.Instances {
+			from := resource.Address(instance.IndexKey)
+			to, ok := movedAddress(from, moves)
+
+			change := AddressChange{From: from, To: to, Status: ChangeUntouched, deposed: instance.Deposed}
+			switch {
+			case !ok:
+				change.Status = ChangeConflicting
+			case !to.Equal(from):
+				change.Status = ChangeMoved
+			}
+
+			target := to.String() + instance.Deposed
+			targets[target] = append(targets[target], len(changes))
+			if keyKinds[to.Resource().String()] == nil {
+				keyKinds[to.Resource().String()] = map[indexKeyKind]bool{}
+			}
+			keyKinds[to.Resource().String()][to.Key.kind] = true
+			changes = append(changes, change)
+		}
+	}
+
+	for index, change := range changes {
+		if len(targets[change.To.String()+change.deposed]) > 1 || len(keyKinds[change.To.Resource().String()]) > 1 {
+			changes[index].Status = ChangeConflicting
+		}
+	}
+	return changes
+}
+
+// ApplyMoves returns a copy of the state where resource instances are relocated by moves.
+// Instances moved to the same resource are grouped. The serial of the copy is incremented and
+// its lineage kept, as it is a new version of the state. An error is returned when moves conflict.
+func (s TerraformState) ApplyMoves(moves []Move) (*TerraformState, error) {
+	conflicts := []string{}
+	for _, change := range s.Changes(moves) {
+		if change.Status == ChangeConflicting {
+			conflicts = append(conflicts, fmt.Sprintf("%s -> %s", change.From, change.To))
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("moves can not be applied, conflicting resource instances:\n  %s", strings.Join(conflicts, "\n  "))
+	}
+
+	output := s
+	output.Serial++
+	output.Resources = []TerraformResource{}
+	positions := map[string]int{}
+	for _, resource := range s.Resources {
+		for _, instance := range resource.Instances {
+			to, _ := movedAddress(resource.Address(instance.IndexKey), moves)
+
+			key := to.Resource().String()
+			position, found := positions[key]
+			if !found {
+				moved := resource
+				moved.Module = to.Module.String()
+				moved.Mode = to.Mode
+				moved.Type = to.Type
+				moved.Name = to.Name
+				moved.Instances = nil
+				position = len(output.Resources)
+				positions[key] = position
+				output.Resources = append(output.Resources, moved)
+			}
+
+			instance.IndexKey = to.Key
+			output.Resources[position].Instances = append(output.Resources[position].Instances, instance)
+		}
+	}
+
+	for index, resource := range output.Resources {
+		if resource.Each != "" && len(resource.Instances) > 0 {
+			output.Resources[index].Each = eachMode(resource.Instances[0].IndexKey)
+		}
+	}
+	return &output, nil
+}
+
+// eachMode returns the each field of a resource whose instances have the given kind of key
+func eachMode(key IndexKey) string {
+	switch {
+	case key.IsInt():
+		return "list"
+	case key.IsString():
+		return "map"
+	default:
+		return ""
+	}
+}
+
+// movedAddress returns the address of a resource instance once moves are applied, following
+// chained moves. ok is false when moves form a cycle.
+func movedAddress(address Address, moves []Move) (Address, bool) {
+	visited := map[string]bool{address.String(): true}
+	for {
+		moved := false
+		for _, move := range moves {
+			if next, ok := move.apply(address); ok {
+				address, moved = next, true
+				break
+			}
+		}
+		if !moved {
+			return address, true
+		}
+		if visited[address.String()] {
+			return address, false
+		}
+		visited[address.String()] = true
+	}
+}
+
+// apply returns the new address of the resource instance at address when the move relocates
+// it. As in terraform, a move without key relocates every instance of a resource or module
+// call when the target has no key either.
+func (m Move) apply(address Address) (Address, bool) {
+	if m.From.IsModule() {
+		module, ok := moveModule(address.Module, m.From.Module, m.To.Module)
+		if !ok {
+			return Address{}, false
+		}
+		address.Module = module
+		return address, true
+	}
+
+	if !address.Resource().Equal(m.From.Resource()) {
+		return Address{}, false
+	}
+	switch {
+	case address.Key == m.From.Key:
+		return m.To, true
+	case m.From.Key.IsNone() && m.To.Key.IsNone():
+		return m.To.WithKey(address.Key), true
+	default:
+		return Address{}, false
+	}
+}
+
+// moveModule replaces the from prefix of module by to. A last step of from without key
+// matches every instance of the module call, whose key is kept when to has no key either.
+func moveModule(module ModulePath, from ModulePath, to ModulePath) (ModulePath, bool) {
+	if len(from) == 0 || len(to) == 0 || len(module) < len(from) {
+		return nil, false
+	}
+
+	last := len(from) - 1
+	for index, step := range from {
+		if step.Name != module[index].Name {
+			return nil, false
+		}
+		if step.Key != module[index].Key && !(index == last && step.Key.IsNone() && to[len(to)-1].Key.IsNone()) {
+			return nil, false
+		}
+	}
+
+	output := append(ModulePath{}, to...)
+	if from[last].Key.IsNone() && !module[last].Key.IsNone() {
+		output[len(output)-1].Key = module[last].Key
+	}
+	return append(output, module[len(from):]...), true
+}
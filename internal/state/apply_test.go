@@ -0,0 +1,104 @@
+/*
+MIT License
+
+# Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state_test
+
+import (
+	"testing"
+
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChanges(t *testing.T) {
+	t.Run("Each instance should be reported with its status", func(t *testing.T) {
+		moves := []state.Move{
+			{From: mustModuleAddress(t, "module.legacy_vpc"), To: mustModuleAddress(t, "module.vpc")},
+			{From: mustAddress(t, "aws_sqs_queue.jobs"), To: mustAddress(t, `aws_sqs_queue.jobs["default"]`)},
+		}
+
+		changes := modulesState().Changes(moves)
+		assert.Len(t, changes, 7)
+		assert.Equal(t, "module.vpc.aws_vpc.main", changes[0].To.String())
+		assert.Equal(t, state.ChangeMoved, changes[0].Status)
+		assert.Equal(t, "module.vpc.module.subnets.aws_subnet.private[1]", changes[2].To.String())
+		assert.Equal(t, `module.app["eu"].aws_s3_bucket.logs`, changes[4].To.String())
+		assert.Equal(t, state.ChangeUntouched, changes[4].Status)
+		assert.Equal(t, `aws_sqs_queue.jobs["default"]`, changes[6].To.String())
+		assert.Equal(t, state.ChangeMoved, changes[6].Status)
+	})
+
+	t.Run("Module calls without key should move every instance", func(t *testing.T) {
+		moves := []state.Move{{From: mustModuleAddress(t, "module.app"), To: mustModuleAddress(t, "module.storage")}}
+
+		changes := modulesState().Changes(moves)
+		assert.Equal(t, `module.storage["eu"].aws_s3_bucket.logs`, changes[4].To.String())
+		assert.Equal(t, `module.storage["us"].aws_s3_bucket.logs`, changes[5].To.String())
+	})
+
+	t.Run("Instances moved to the same address should be conflicting", func(t *testing.T) {
+		moves := []state.Move{{From: mustAddress(t, `module.app["eu"].aws_s3_bucket.logs`), To: mustAddress(t, `module.app["us"].aws_s3_bucket.logs`)}}
+
+		changes := modulesState().Changes(moves)
+		assert.Equal(t, state.ChangeConflicting, changes[4].Status)
+		assert.Equal(t, state.ChangeConflicting, changes[5].Status)
+		assert.Equal(t, state.ChangeUntouched, changes[0].Status)
+	})
+
+	t.Run("Cyclic moves should be conflicting", func(t *testing.T) {
+		moves := []state.Move{
+			{From: mustAddress(t, "aws_sqs_queue.jobs"), To: mustAddress(t, "aws_sqs_queue.tasks")},
+			{From: mustAddress(t, "aws_sqs_queue.tasks"), To: mustAddress(t, "aws_sqs_queue.jobs")},
+		}
+
+		changes := modulesState().Changes(moves)
+		assert.Equal(t, state.ChangeConflicting, changes[6].Status)
+	})
+}
+
+func TestApplyMoves(t *testing.T) {
+	t.Run("Instances should be relocated", func(t *testing.T) {
+		moves := []state.Move{
+			{From: mustModuleAddress(t, "module.legacy_vpc.module.subnets"), To: mustModuleAddress(t, "module.subnets")},
+			{From: mustAddress(t, `module.app["eu"].aws_s3_bucket.logs`), To: mustAddress(t, `aws_s3_bucket.logs["eu"]`)},
+			{From: mustAddress(t, `module.app["us"].aws_s3_bucket.logs`), To: mustAddress(t, `aws_s3_bucket.logs["us"]`)},
+		}
+
+		original := modulesState()
+		moved, err := original.ApplyMoves(moves)
+		assert.Nil(t, err)
+		assert.Len(t, moved.Resources, 5)
+		assert.Equal(t, "module.subnets", moved.Resources[1].Module)
+		assert.Len(t, moved.Resources[1].Instances, 2)
+		assert.Equal(t, "", moved.Resources[3].Module)
+		assert.Equal(t, []state.TerraformResourceValue{{IndexKey: state.StringKey("eu")}, {IndexKey: state.StringKey("us")}}, moved.Resources[3].Instances)
+		assert.Equal(t, "module.legacy_vpc.module.subnets", original.Resources[1].Module)
+	})
+
+	t.Run("Conflicting moves should returns an error", func(t *testing.T) {
+		moves := []state.Move{{From: mustAddress(t, "aws_sqs_queue.jobs"), To: mustAddress(t, "module.legacy_vpc.aws_vpc.main")}}
+
+		_, err := modulesState().ApplyMoves(moves)
+		assert.NotNil(t, err)
+	})
+}
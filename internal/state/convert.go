@@ -0,0 +1,275 @@
+// Package state list all related resource to terraform state file
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// KeyConversion changes the index keys of the instances of a resource, for instance when a
+// resource created with count is converted to for_each.
+type KeyConversion interface {
+	// ConvertKeys returns the new key of each instance of resource, in the same order
+	ConvertKeys(resource TerraformResource) ([]IndexKey, error)
+}
+
+// ConvertedMoves returns the moves of the resources selected by mapping, the instance keys
+// being changed by conversion. Instances whose address does not change are not moved, nor
+// data sources.
+func (s TerraformState) ConvertedMoves(mapping Mapping, conversion KeyConversion) ([]Move, error) {
+	moves := []Move{}
+	for _, resource := range s.ListResources(mapping) {
+		if resource.Mode == DataMode {
+			continue
+		}
+		target, err := mapping.Target(resource)
+		if err != nil {
+			return nil, err
+		}
+
+		converted, err := ConvertedMovesFor(resource, target, conversion)
+		if err != nil {
+			return nil, err
+		}
+		moves = append(moves, converted...)
+	}
+	return moves, nil
+}
+
+// ConvertedMovesFor returns the moves relocating every instance of resource to newLocation
+// like MovesFor, except that instance keys are computed by conversion. An error is returned
+// when two instances would get the same key.
+func ConvertedMovesFor(resource TerraformResource, newLocation Address, conversion KeyConversion) ([]Move, error) {
+	current := []TerraformResourceValue{}
+	for _, instance := range resource.Instances {
+		if instance.Deposed == "" {
+			current = append(current, instance)
+		}
+	}
+	resource.Instances = current
+
+	keys, err := conversion.ConvertKeys(resource)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) != len(resource.Instances) {
+		return nil, fmt.Errorf("%s: %d keys computed for %d instances", resource, len(keys), len(resource.Instances))
+	}
+
+	moves := []Move{}
+	sources := map[IndexKey]IndexKey{}
+	for index, instance := range resource.Instances {
+		if previous, found := sources[keys[index]]; found {
+			return nil, fmt.Errorf("instances %s and %s would both be moved to %s", resource.Address(previous), resource.Address(instance.IndexKey), newLocation.WithKey(keys[index]))
+		}
+		sources[keys[index]] = instance.IndexKey
+
+		move := Move{From: resource.Address(instance.IndexKey), To: newLocation.WithKey(keys[index])}
+		if !move.From.Equal(move.To) {
+			moves = append(moves, move)
+		}
+	}
+	return moves, nil
+}
+
+// ForEachConversion converts resources created with count, or without count nor for_each, to
+// for_each. The for_each key of each instance is derived from its attributes with a template
+// such as {{ .attributes.name }}, or a constant such as default for a single resource.
+type ForEachConversion struct {
+	KeyTemplate *template.Template
+}
+
+var instanceTemplateFunctions = template.FuncMap{
+	"lower":   strings.ToLower,
+	"upper":   strings.ToUpper,
+	"trim":    strings.TrimSpace,
+	"replace": strings.ReplaceAll,
+}
+
+// parseInstanceTemplate parses a template evaluated on resource instances, which fails when
+// a missing attribute is referenced
+func parseInstanceTemplate(name string, text string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("%s template must not be empty", name)
+	}
+	parsed, err := template.New(name).Funcs(instanceTemplateFunctions).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template %q: %w", name, text, err)
+	}
+	return parsed, nil
+}
+
+// executeInstanceTemplate evaluates a template on a resource instance. The template receives
+// .attributes (instance attributes without sensitive values), .index (count index), .key
+// (for_each key), .type, .name and .module.
+func executeInstanceTemplate(parsed *template.Template, resource TerraformResource, instance TerraformResourceValue) (string, error) {
+	data := map[string]interface{}{
+		"attributes": attributeScope{attributes: instance.Attributes, sensitive: parseSensitivePaths(instance.SensitiveAttributes)}.redacted(),
+		"index":      instance.IndexKey.AsInt(),
+		"key":        instance.IndexKey.AsString(),
+		"type":       resource.Type,
+		"name":       resource.Name,
+		"module":     resource.Module,
+	}
+
+	var builder strings.Builder
+	if err := parsed.Execute(&builder, data); err != nil {
+		return "", fmt.Errorf("%s of instance %s: %w", parsed.Name(), resource.Address(instance.IndexKey), err)
+	}
+	if builder.Len() == 0 {
+		return "", fmt.Errorf("%s of instance %s is empty", parsed.Name(), resource.Address(instance.IndexKey))
+	}
+	return builder.String(), nil
+}
+
+// NewForEachConversion parses the template deriving for_each keys. The template receives
+// .attributes (instance attributes without sensitive values), .index (count index),
+// .type, .name and .module, and may use the lower, upper, trim and replace functions.
+func NewForEachConversion(keyTemplate string) (*ForEachConversion, error) {
+	parsed, err := parseInstanceTemplate("key", keyTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return &ForEachConversion{KeyTemplate: parsed}, nil
+}
+
+// ConvertKeys derives the for_each key of every instance of a resource created with count or
+// of a single resource
+func (c ForEachConversion) ConvertKeys(resource TerraformResource) ([]IndexKey, error) {
+	keys := make([]IndexKey, 0, len(resource.Instances))
+	for _, instance := range resource.Instances {
+		if instance.IndexKey.IsString() {
+			return nil, fmt.Errorf("instance %s is already created with for_each", resource.Address(instance.IndexKey))
+		}
+
+		key, err := executeInstanceTemplate(c.KeyTemplate, resource, instance)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, StringKey(key))
+	}
+	return keys, nil
+}
+
+// Orders of for_each keys when resources are converted to count
+const (
+	// OrderByKey sorts for_each keys lexically, as terraform iterates over for_each maps
+	OrderByKey = "key"
+	// OrderByState keeps the order of instances in the state file
+	OrderByState = "state"
+)
+
+// CountConversion converts resources created with for_each, or without count nor for_each,
+// to count. Instances are numbered from 0 following Order.
+type CountConversion struct {
+	Order string
+}
+
+// NewCountConversion creates a CountConversion numbering instances in the given order,
+// OrderByKey when order is empty
+func NewCountConversion(order string) (*CountConversion, error) {
+	switch order {
+	case "":
+		return &CountConversion{Order: OrderByKey}, nil
+	case OrderByKey, OrderByState:
+		return &CountConversion{Order: order}, nil
+	default:
+		return nil, fmt.Errorf("invalid order %q: expected %s or %s", order, OrderByKey, OrderByState)
+	}
+}
+
+// ConvertKeys numbers the instances of a resource created with for_each or of a single resource
+func (c CountConversion) ConvertKeys(resource TerraformResource) ([]IndexKey, error) {
+	positions := make([]int, 0, len(resource.Instances))
+	for index, instance := range resource.Instances {
+		if instance.IndexKey.IsInt() {
+			return nil, fmt.Errorf("instance %s is already created with count", resource.Address(instance.IndexKey))
+		}
+		positions = append(positions, index)
+	}
+
+	if c.Order != OrderByState {
+		sort.SliceStable(positions, func(i, j int) bool {
+			return resource.Instances[positions[i]].IndexKey.AsString() < resource.Instances[positions[j]].IndexKey.AsString()
+		})
+	}
+
+	keys := make([]IndexKey, len(resource.Instances))
+	for rank, position := range positions {
+		keys[position] = IntKey(rank)
+	}
+	return keys, nil
+}
+
+// SingletonConversion converts a resource created with count or for_each holding a single
+// instance to a resource without count nor for_each
+type SingletonConversion struct{}
+
+// ConvertKeys removes the key of the single instance of a resource
+func (c SingletonConversion) ConvertKeys(resource TerraformResource) ([]IndexKey, error) {
+	if len(resource.Instances) != 1 {
+		return nil, fmt.Errorf("%s has %d instances, a single one is expected", resource, len(resource.Instances))
+	}
+	return []IndexKey{NoKey}, nil
+}
+
+// redacted returns a copy of the attributes of the scope without the sensitive values, which
+// --where expressions never match either. Values holding sensitive values are kept without
+// them, as expressions can still reach their other elements.
+func (s attributeScope) redacted() map[string]interface{} {
+	redacted, _ := s.redactValue(s.attributes, attributePath{}).(map[string]interface{})
+	return redacted
+}
+
+func (s attributeScope) redactValue(value interface{}, path attributePath) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		output := make(map[string]interface{}, len(typed))
+		for key, element := range typed {
+			child := append(append(attributePath{}, path...), key)
+			if s.isRedacted(child) {
+				continue
+			}
+			output[key] = s.redactValue(element, child)
+		}
+		return output
+	case []interface{}:
+		output := make([]interface{}, 0, len(typed))
+		for index, element := range typed {
+			child := append(append(attributePath{}, path...), index)
+			if s.isRedacted(child) {
+				output = append(output, nil)
+				continue
+			}
+			output = append(output, s.redactValue(element, child))
+		}
+		return output
+	default:
+		return value
+	}
+}
@@ -0,0 +1,243 @@
+/*
+MIT License
+
+# Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state_test
+
+import (
+	"testing"
+
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func countState() state.TerraformState {
+	return state.TerraformState{
+		Version: 4,
+		Resources: []state.TerraformResource{
+			{Mode: state.ManagedMode, Type: "aws_s3_bucket", Name: "logs", Instances: []state.TerraformResourceValue{
+				{IndexKey: state.IntKey(0), Attributes: map[string]interface{}{"bucket": "logs-eu", "tags": map[string]interface{}{"env": "prod"}}},
+				{IndexKey: state.IntKey(1), Attributes: map[string]interface{}{"bucket": "logs-us", "tags": map[string]interface{}{"env": "dev"}}},
+			}},
+			{Mode: state.ManagedMode, Type: "aws_s3_bucket", Name: "keyed", Instances: []state.TerraformResourceValue{
+				{IndexKey: state.StringKey("eu"), Attributes: map[string]interface{}{"bucket": "keyed-eu"}},
+			}},
+		},
+	}
+}
+
+func TestForEachConversion(t *testing.T) {
+	t.Run("Keys should be derived from instance attributes", func(t *testing.T) {
+		conversion, err := state.NewForEachConversion(`{{ .attributes.bucket }}`)
+		assert.Nil(t, err)
+		mapping, err := state.NewLocationMapping("aws_s3_bucket.logs", "aws_s3_bucket.logs")
+		assert.Nil(t, err)
+
+		moves, err := countState().ConvertedMoves(mapping, conversion)
+		assert.Nil(t, err)
+		assert.Len(t, moves, 2)
+		assert.Equal(t, "aws_s3_bucket.logs[0]", moves[0].From.String())
+		assert.Equal(t, `aws_s3_bucket.logs["logs-eu"]`, moves[0].To.String())
+		assert.Equal(t, `aws_s3_bucket.logs["logs-us"]`, moves[1].To.String())
+	})
+
+	t.Run("Conversion should follow the new location", func(t *testing.T) {
+		conversion, err := state.NewForEachConversion(`{{ .attributes.tags.env | upper }}`)
+		assert.Nil(t, err)
+		mapping, err := state.NewLocationMapping("aws_s3_bucket.logs", "module.storage.aws_s3_bucket.this")
+		assert.Nil(t, err)
+
+		moves, err := countState().ConvertedMoves(mapping, conversion)
+		assert.Nil(t, err)
+		assert.Len(t, moves, 2)
+		assert.Equal(t, `module.storage.aws_s3_bucket.this["PROD"]`, moves[0].To.String())
+		assert.Equal(t, `module.storage.aws_s3_bucket.this["DEV"]`, moves[1].To.String())
+	})
+
+	t.Run("Duplicate keys should returns an error", func(t *testing.T) {
+		conversion, err := state.NewForEachConversion(`{{ .type }}`)
+		assert.Nil(t, err)
+		mapping, err := state.NewLocationMapping("aws_s3_bucket.logs", "aws_s3_bucket.logs")
+		assert.Nil(t, err)
+
+		_, err = countState().ConvertedMoves(mapping, conversion)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Missing attribute should returns an error", func(t *testing.T) {
+		conversion, err := state.NewForEachConversion(`{{ .attributes.unknown }}`)
+		assert.Nil(t, err)
+		mapping, err := state.NewLocationMapping("aws_s3_bucket.logs", "aws_s3_bucket.logs")
+		assert.Nil(t, err)
+
+		_, err = countState().ConvertedMoves(mapping, conversion)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Sensitive attributes should not be available", func(t *testing.T) {
+		resource := state.TerraformResource{Mode: state.ManagedMode, Type: "random_password", Name: "db", Instances: []state.TerraformResourceValue{
+			{
+				IndexKey:            state.IntKey(0),
+				Attributes:          map[string]interface{}{"result": "secret"},
+				SensitiveAttributes: []interface{}{[]interface{}{map[string]interface{}{"type": "get_attr", "value": "result"}}},
+			},
+		}}
+		conversion, err := state.NewForEachConversion(`{{ .attributes.result }}`)
+		assert.Nil(t, err)
+
+		_, err = conversion.ConvertKeys(resource)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Templates should hide the attributes hidden from where expressions", func(t *testing.T) {
+		resource := state.TerraformResource{Mode: state.ManagedMode, Type: "aws_instance", Name: "web", Instances: []state.TerraformResourceValue{
+			{
+				IndexKey:   state.IntKey(0),
+				Attributes: map[string]interface{}{"tags": map[string]interface{}{"env": "prod", "team": "core"}},
+				SensitiveAttributes: []interface{}{[]interface{}{
+					map[string]interface{}{"type": "get_attr", "value": "tags"},
+					map[string]interface{}{"type": "index", "value": map[string]interface{}{"value": "env", "type": "string"}},
+				}},
+			},
+		}}
+		for _, test := range []struct {
+			attribute string
+			visible   bool
+		}{{attribute: "team", visible: true}, {attribute: "env", visible: false}} {
+			conversion, err := state.NewForEachConversion(`{{ .attributes.tags.` + test.attribute + ` }}`)
+			assert.Nil(t, err)
+			where, err := state.ParseWhere(`tags.` + test.attribute)
+			assert.Nil(t, err)
+
+			_, err = conversion.ConvertKeys(resource)
+			assert.Equal(t, test.visible, err == nil, test.attribute)
+			assert.Equal(t, test.visible, where.MatchesInstance(resource, resource.Instances[0]), test.attribute)
+		}
+	})
+
+	t.Run("Single resource should get a constant key", func(t *testing.T) {
+		resource := state.TerraformResource{Mode: state.ManagedMode, Type: "aws_vpc", Name: "main", Instances: []state.TerraformResourceValue{{}}}
+		conversion, err := state.NewForEachConversion(`default`)
+		assert.Nil(t, err)
+
+		moves, err := state.ConvertedMovesFor(resource, resource.Address(state.NoKey), conversion)
+		assert.Nil(t, err)
+		assert.Len(t, moves, 1)
+		assert.Equal(t, `aws_vpc.main["default"]`, moves[0].To.String())
+	})
+
+	t.Run("Resources created with for_each should returns an error", func(t *testing.T) {
+		conversion, err := state.NewForEachConversion(`{{ .attributes.bucket }}`)
+		assert.Nil(t, err)
+
+		_, err = conversion.ConvertKeys(countState().Resources[1])
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Invalid template should returns an error", func(t *testing.T) {
+		_, err := state.NewForEachConversion(`{{ .attributes.bucket `)
+		assert.NotNil(t, err)
+		_, err = state.NewForEachConversion(` `)
+		assert.NotNil(t, err)
+	})
+}
+
+func keyedResource() state.TerraformResource {
+	return state.TerraformResource{Mode: state.ManagedMode, Type: "aws_iam_user", Name: "users", Instances: []state.TerraformResourceValue{
+		{IndexKey: state.StringKey("carol")},
+		{IndexKey: state.StringKey("alice")},
+		{IndexKey: state.StringKey("bob")},
+	}}
+}
+
+func TestCountConversion(t *testing.T) {
+	t.Run("Keys should be sorted by default", func(t *testing.T) {
+		conversion, err := state.NewCountConversion("")
+		assert.Nil(t, err)
+
+		moves, err := state.ConvertedMovesFor(keyedResource(), keyedResource().Address(state.NoKey), conversion)
+		assert.Nil(t, err)
+		assert.Len(t, moves, 3)
+		assert.Equal(t, `aws_iam_user.users["carol"]`, moves[0].From.String())
+		assert.Equal(t, "aws_iam_user.users[2]", moves[0].To.String())
+		assert.Equal(t, "aws_iam_user.users[0]", moves[1].To.String())
+		assert.Equal(t, "aws_iam_user.users[1]", moves[2].To.String())
+	})
+
+	t.Run("State order should be kept", func(t *testing.T) {
+		conversion, err := state.NewCountConversion(state.OrderByState)
+		assert.Nil(t, err)
+
+		keys, err := conversion.ConvertKeys(keyedResource())
+		assert.Nil(t, err)
+		assert.Equal(t, []state.IndexKey{state.IntKey(0), state.IntKey(1), state.IntKey(2)}, keys)
+	})
+
+	t.Run("Single resource should become the first instance", func(t *testing.T) {
+		resource := state.TerraformResource{Mode: state.ManagedMode, Type: "aws_vpc", Name: "main", Instances: []state.TerraformResourceValue{{}}}
+		conversion, err := state.NewCountConversion("")
+		assert.Nil(t, err)
+
+		moves, err := state.ConvertedMovesFor(resource, resource.Address(state.NoKey), conversion)
+		assert.Nil(t, err)
+		assert.Len(t, moves, 1)
+		assert.Equal(t, "aws_vpc.main[0]", moves[0].To.String())
+	})
+
+	t.Run("Resources created with count should returns an error", func(t *testing.T) {
+		conversion, err := state.NewCountConversion("")
+		assert.Nil(t, err)
+
+		_, err = conversion.ConvertKeys(countState().Resources[0])
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Unknown order should returns an error", func(t *testing.T) {
+		_, err := state.NewCountConversion("random")
+		assert.NotNil(t, err)
+	})
+}
+
+func TestSingletonConversion(t *testing.T) {
+	t.Run("Single instance should lose its key", func(t *testing.T) {
+		resource := state.TerraformResource{Mode: state.ManagedMode, Type: "aws_vpc", Name: "main", Instances: []state.TerraformResourceValue{{IndexKey: state.IntKey(0)}}}
+
+		moves, err := state.ConvertedMovesFor(resource, resource.Address(state.NoKey), state.SingletonConversion{})
+		assert.Nil(t, err)
+		assert.Len(t, moves, 1)
+		assert.Equal(t, "aws_vpc.main[0]", moves[0].From.String())
+		assert.Equal(t, "aws_vpc.main", moves[0].To.String())
+	})
+
+	t.Run("Unchanged single resource should not be moved", func(t *testing.T) {
+		resource := state.TerraformResource{Mode: state.ManagedMode, Type: "aws_vpc", Name: "main", Instances: []state.TerraformResourceValue{{}}}
+
+		moves, err := state.ConvertedMovesFor(resource, resource.Address(state.NoKey), state.SingletonConversion{})
+		assert.Nil(t, err)
+		assert.Empty(t, moves)
+	})
+
+	t.Run("Several instances should returns an error", func(t *testing.T) {
+		_, err := state.SingletonConversion{}.ConvertKeys(keyedResource())
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,199 @@
+// Package state list all related resource to terraform state file
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// decodeObject decodes the json object data into value, a pointer to a struct, numbers being
+// decoded as json.Number. The fields of the object unknown to value are returned.
+func decodeObject(data []byte, value interface{}) (map[string]json.RawMessage, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(value); err != nil {
+		return nil, err
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for _, name := range jsonFieldNames(value) {
+		delete(fields, name)
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields, nil
+}
+
+// encodeObject encodes value, a struct, as a json object followed by the extra fields sorted
+// by name
+func encodeObject(value interface{}, extra map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+
+	names := make([]string, 0, len(extra))
+	for name := range extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buffer bytes.Buffer
+	buffer.Write(data[:len(data)-1])
+	for index, name := range names {
+		if index > 0 || len(data) > 2 {
+			buffer.WriteByte(',')
+		}
+		encodedName, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(encodedName)
+		buffer.WriteByte(':')
+		buffer.Write(extra[name])
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
+
+// jsonFieldNames returns the json names of the fields of value, a struct or a pointer to a struct
+func jsonFieldNames(value interface{}) []string {
+	structType := reflect.TypeOf(value)
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+
+	names := []string{}
+	for index := 0; index < structType.NumField(); index++ {
+		name := strings.Split(structType.Field(index).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// UnmarshalJSON decodes a terraform state, keeping the fields not modelled in Extra
+func (s *TerraformState) UnmarshalJSON(data []byte) error {
+	type plain TerraformState
+	decoded := plain{}
+	extra, err := decodeObject(data, &decoded)
+	if err != nil {
+		return err
+	}
+	*s = TerraformState(decoded)
+	s.Extra = extra
+	return nil
+}
+
+// MarshalJSON encodes a terraform state along with the fields kept in Extra
+func (s TerraformState) MarshalJSON() ([]byte, error) {
+	type plain TerraformState
+	encoded := plain(s)
+	if encoded.Outputs == nil {
+		encoded.Outputs = map[string]TerraformOutputValue{}
+	}
+	if encoded.Resources == nil {
+		encoded.Resources = []TerraformResource{}
+	}
+	return encodeObject(encoded, s.Extra)
+}
+
+// UnmarshalJSON decodes a resource, keeping the fields not modelled in Extra
+func (resource *TerraformResource) UnmarshalJSON(data []byte) error {
+	type plain TerraformResource
+	decoded := plain{}
+	extra, err := decodeObject(data, &decoded)
+	if err != nil {
+		return err
+	}
+	*resource = TerraformResource(decoded)
+	resource.Extra = extra
+	return nil
+}
+
+// MarshalJSON encodes a resource along with the fields kept in Extra
+func (resource TerraformResource) MarshalJSON() ([]byte, error) {
+	type plain TerraformResource
+	encoded := plain(resource)
+	if encoded.Instances == nil {
+		encoded.Instances = []TerraformResourceValue{}
+	}
+	return encodeObject(encoded, resource.Extra)
+}
+
+// instanceObject is the json representation of a resource instance, where index_key is
+// omitted when the instance has no key, as written by terraform
+type instanceObject struct {
+	IndexKey            *IndexKey               `json:"index_key,omitempty"`
+	Deposed             string                  `json:"deposed,omitempty"`
+	SchemaVersion       int                     `json:"schema_version"`
+	Attributes          *map[string]interface{} `json:"attributes,omitempty"`
+	SensitiveAttributes *[]interface{}          `json:"sensitive_attributes,omitempty"`
+}
+
+// UnmarshalJSON decodes a resource instance, keeping the fields not modelled in Extra
+func (v *TerraformResourceValue) UnmarshalJSON(data []byte) error {
+	decoded := instanceObject{}
+	extra, err := decodeObject(data, &decoded)
+	if err != nil {
+		return err
+	}
+
+	*v = TerraformResourceValue{SchemaVersion: decoded.SchemaVersion, Deposed: decoded.Deposed, Extra: extra}
+	if decoded.IndexKey != nil {
+		v.IndexKey = *decoded.IndexKey
+	}
+	if decoded.Attributes != nil {
+		v.Attributes = *decoded.Attributes
+	}
+	if decoded.SensitiveAttributes != nil {
+		v.SensitiveAttributes = *decoded.SensitiveAttributes
+	}
+	return nil
+}
+
+// MarshalJSON encodes a resource instance along with the fields kept in Extra
+func (v TerraformResourceValue) MarshalJSON() ([]byte, error) {
+	encoded := instanceObject{SchemaVersion: v.SchemaVersion, Deposed: v.Deposed}
+	if !v.IndexKey.IsNone() {
+		encoded.IndexKey = &v.IndexKey
+	}
+	if v.Attributes != nil {
+		encoded.Attributes = &v.Attributes
+	}
+	if v.SensitiveAttributes != nil {
+		encoded.SensitiveAttributes = &v.SensitiveAttributes
+	}
+	return encodeObject(encoded, v.Extra)
+}
@@ -0,0 +1,185 @@
+/*
+MIT License
+
+# Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/stretchr/testify/assert"
+)
+
+const fullState = `{
+  "version": 4,
+  "terraform_version": "1.3.2",
+  "serial": 12,
+  "lineage": "5a1c2b3d-0000-4e5f-8a9b-0c1d2e3f4a5b",
+  "outputs": {
+    "ids": {
+      "value": [1, 2.5],
+      "type": ["tuple", ["number", "number"]]
+    }
+  },
+  "resources": [
+    {
+      "mode": "managed",
+      "type": "aws_instance",
+      "name": "web",
+      "each": "list",
+      "provider": "provider[\"registry.terraform.io/hashicorp/aws\"]",
+      "instances": [
+        {
+          "index_key": 0,
+          "status": "tainted",
+          "schema_version": 1,
+          "attributes": {"id": "i-0123", "cpu": 12345678901234567890},
+          "sensitive_attributes": [],
+          "private": "eyJzY2hlbWFfdmVyc2lvbiI6IjEifQ==",
+          "dependencies": ["aws_vpc.main"],
+          "create_before_destroy": true
+        },
+        {
+          "index_key": 0,
+          "deposed": "00000001",
+          "schema_version": 1,
+          "attributes": {"id": "i-0042"},
+          "sensitive_attributes": []
+        }
+      ]
+    },
+    {
+      "module": "module.network",
+      "mode": "managed",
+      "type": "aws_vpc",
+      "name": "main",
+      "provider": "provider[\"registry.terraform.io/hashicorp/aws\"]",
+      "instances": [
+        {
+          "schema_version": 1,
+          "attributes": {"id": "vpc-0123"},
+          "sensitive_attributes": []
+        }
+      ]
+    }
+  ],
+  "check_results": [{"object_kind": "resource", "config_addr": "aws_instance.web", "status": "pass"}]
+}`
+
+func TestToWriter(t *testing.T) {
+	t.Run("Fields not modelled should be written back unchanged", func(t *testing.T) {
+		terraformState, err := state.FromReader(strings.NewReader(fullState))
+		assert.Nil(t, err)
+		assert.Equal(t, "00000001", terraformState.Resources[0].Instances[1].Deposed)
+		assert.True(t, terraformState.Resources[1].Instances[0].IndexKey.IsNone())
+
+		var buffer bytes.Buffer
+		assert.Nil(t, terraformState.ToWriter(&buffer))
+		assert.JSONEq(t, fullState, buffer.String())
+		assert.NotContains(t, buffer.String(), `"index_key": null`)
+	})
+}
+
+func TestToFile(t *testing.T) {
+	t.Run("Previous state should be saved before writing", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "terraform.tfstate")
+		assert.Nil(t, os.WriteFile(path, []byte(fullState), 0o600))
+
+		terraformState, err := state.FromFile(path)
+		assert.Nil(t, err)
+		terraformState.Serial++
+		assert.Nil(t, terraformState.ToFile(path))
+
+		backup, err := os.ReadFile(path + state.BackupSuffix)
+		assert.Nil(t, err)
+		assert.Equal(t, fullState, string(backup))
+
+		written, err := state.FromFile(path)
+		assert.Nil(t, err)
+		assert.Equal(t, 13, written.Serial)
+
+		info, err := os.Stat(path)
+		assert.Nil(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	})
+
+	t.Run("Locked state should not be written", func(t *testing.T) {
+		directory := t.TempDir()
+		path := filepath.Join(directory, "terraform.tfstate")
+		assert.Nil(t, os.WriteFile(path, []byte(fullState), 0o600))
+		assert.Nil(t, os.WriteFile(filepath.Join(directory, ".terraform.tfstate.lock.info"), []byte("{}"), 0o600))
+
+		err := state.TerraformState{Version: 4}.ToFile(path)
+		assert.ErrorIs(t, err, state.ErrStateLocked)
+
+		content, err := os.ReadFile(path)
+		assert.Nil(t, err)
+		assert.Equal(t, fullState, string(content))
+	})
+
+	t.Run("State read from terraform show output should not be written", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "terraform.tfstate")
+		terraformState, err := state.FromReader(strings.NewReader(`{"format_version": "1.0"}`))
+		assert.Nil(t, err)
+
+		assert.ErrorIs(t, terraformState.ToFile(path), state.ErrNoLineage)
+		_, err = os.Stat(path)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("CheckWritable should returns an error for locked states and directories", func(t *testing.T) {
+		directory := t.TempDir()
+		path := filepath.Join(directory, "terraform.tfstate")
+		assert.Nil(t, state.CheckWritable(path))
+
+		assert.Nil(t, os.WriteFile(filepath.Join(directory, ".terraform.tfstate.lock.info"), []byte("{}"), 0o600))
+		assert.ErrorIs(t, state.CheckWritable(path), state.ErrStateLocked)
+		assert.NotNil(t, state.CheckWritable(directory))
+	})
+}
+
+func TestApplyMovesToFullState(t *testing.T) {
+	t.Run("Deposed objects should follow their instance", func(t *testing.T) {
+		terraformState, err := state.FromReader(strings.NewReader(fullState))
+		assert.Nil(t, err)
+		moves := []state.Move{{From: mustAddress(t, "aws_instance.web[0]"), To: mustAddress(t, `module.compute.aws_instance.web["a"]`)}}
+
+		moved, err := terraformState.ApplyMoves(moves)
+		assert.Nil(t, err)
+		assert.Equal(t, 13, moved.Serial)
+		assert.Equal(t, terraformState.Lineage, moved.Lineage)
+
+		resource := moved.Resources[0]
+		assert.Equal(t, "module.compute", resource.Module)
+		assert.Equal(t, "map", resource.Each)
+		assert.Len(t, resource.Instances, 2)
+		assert.Equal(t, state.StringKey("a"), resource.Instances[1].IndexKey)
+		assert.Equal(t, json.RawMessage(`["aws_vpc.main"]`), resource.Instances[0].Extra["dependencies"])
+		assert.Equal(t, 12, terraformState.Serial)
+	})
+}
@@ -30,35 +30,73 @@ import (
 	"strings"
 )
 
-const filterErrorMsg = "Filter must conform pattern: type.name or module.module_name.type.name"
+const filterErrorMsg = "Filter must conform pattern: [data.]type.name, module.module_name.[data.]type.name or module.module_name (modules can be nested)"
 
 // ResourceFilter is a struct giving criteria to filter resources
 type ResourceFilter struct {
-	Type     string
-	Mode     string
-	Name     string
+	Type string
+	Mode string
+	Name string
+	// Provider is either a provider configuration address or a ProviderFilter specification
 	Provider string
 	Module   string
+	// ModuleSubtree also selects resources of modules nested in Module
+	ModuleSubtree bool
 }
 
 func (f ResourceFilter) matchType(value string) bool {
-	return (strings.TrimSpace(f.Type) == "") || (value == f.Type)
+	return matchGlob(strings.TrimSpace(f.Type), value)
 }
 
 func (f ResourceFilter) matchMode(value string) bool {
-	return (strings.TrimSpace(f.Mode) == "") || (value == f.Mode)
+	return matchGlob(strings.TrimSpace(f.Mode), value)
 }
 
 func (f ResourceFilter) matchModule(value string) bool {
-	return (strings.TrimSpace(f.Module) == "") || (value == f.Module)
+	if strings.TrimSpace(f.Module) == "" || value == f.Module {
+		return true
+	}
+
+	pattern, err := ParseModulePath(f.Module)
+	if err != nil {
+		return false
+	}
+	module, err := ParseModulePath(value)
+	if err != nil {
+		return false
+	}
+
+	if f.ModuleSubtree {
+		return module.HasPrefix(pattern)
+	}
+	return pattern.Matches(module)
 }
 
 func (f ResourceFilter) matchName(value string) bool {
-	return (strings.TrimSpace(f.Name) == "") || (value == f.Name)
+	return matchGlob(strings.TrimSpace(f.Name), value)
 }
 
+// matchProvider accepts either a provider configuration address, compared to the resource
+// provider once both are parsed, or a ProviderFilter specification such as hashicorp/aws.eu_west.
 func (f ResourceFilter) matchProvider(value string) bool {
-	return (strings.TrimSpace(f.Provider) == "") || (value == f.Provider)
+	if strings.TrimSpace(f.Provider) == "" || value == f.Provider {
+		return true
+	}
+
+	provider, err := ParseProviderAddress(value)
+	if err != nil {
+		return false
+	}
+
+	if expected, err := ParseProviderAddress(f.Provider); err == nil {
+		return expected.String() == provider.String()
+	}
+
+	filter, err := ParseProviderFilter(f.Provider)
+	if err != nil {
+		return false
+	}
+	return filter.Matches(provider)
 }
 
 // Matches return true if all ResourceFilter properties matches specified resource
@@ -78,35 +116,215 @@ func (f ResourceFilter) isEmptyFilter() bool {
 	return f.Mode == "" && f.Type == "" && f.Provider == "" && f.Module == "" && f.Name == ""
 }
 
-// CreateResourceFilterFromString create a ResourceFilter from a string
+// CreateResourceFilterFromString create a ResourceFilter from a string. The string is either
+// a resource address (type.name or module.module_name.type.name) or a module address
+// (module.module_name) selecting every resource of the module and its nested modules.
+// Module instance keys are supported, [*] selecting every instance of a module. Names may
+// contain globs (aws_s3_bucket_*.logs, module.*.aws_iam_role.*), a module name with globs
+// selecting every instance of the matching modules, and module names may use ** to select
+// nested modules at any depth (module.team_**).
 func CreateResourceFilterFromString(filter string) (*ResourceFilter, error) {
 	output := ResourceFilter{}
 	if strings.TrimSpace(filter) != "" {
-		fields := strings.Split(filter, ".")
+		steps, err := parseTraversal(filter)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filterErrorMsg, err)
+		}
+
+		modules, steps, err := parseModuleSteps(steps)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filterErrorMsg, err)
+		}
+		output.Module = modules.String()
 
-		if strings.HasPrefix(filter, "module.") {
-			if len(fields) != 4 {
+		switch len(steps) {
+		case 0:
+			if modules.IsRoot() {
+				return nil, errors.New(filterErrorMsg)
+			}
+			output.ModuleSubtree = true
+		case 3:
+			if steps[0].name != DataMode || !steps[0].key.IsNone() || !steps[1].key.IsNone() || !steps[2].key.IsNone() {
 				return nil, errors.New(filterErrorMsg)
 			}
-			output.Module = fmt.Sprintf("%s.%s", fields[0], fields[1])
-			fields = fields[2:]
-		} else if len(fields) != 2 {
+			output.Mode = DataMode
+			output.Type = steps[1].name
+			output.Name = steps[2].name
+		case 2:
+			if !steps[0].key.IsNone() || !steps[1].key.IsNone() {
+				return nil, errors.New(filterErrorMsg)
+			}
+			output.Type = steps[0].name
+			output.Name = steps[1].name
+		default:
 			return nil, errors.New(filterErrorMsg)
 		}
+	}
 
-		for index, field := range fields {
-			if strings.TrimSpace(field) == "" {
-				return nil, errors.New(filterErrorMsg)
-			}
-			switch index {
-			case 0:
-				output.Type = field
-			case 1:
-				output.Name = field
+	return &output, nil
+}
+
+// ExpandTarget replaces each [*] key found in the modules of target by the key of the
+// resource module instance matched by the corresponding [*] key of the filter.
+func (f ResourceFilter) ExpandTarget(resource TerraformResource, target string) (string, error) {
+	if !strings.Contains(target, "[*]") {
+		return target, nil
+	}
+
+	pattern, err := ParseModulePath(f.Module)
+	if err != nil {
+		return "", err
+	}
+	module, err := ParseModulePath(resource.Module)
+	if err != nil {
+		return "", err
+	}
+	if pattern.isRecursive() {
+		return "", fmt.Errorf("target %s can not use [*] keys when source location uses **", target)
+	}
+	if !module.HasPrefix(pattern) {
+		return "", fmt.Errorf("resource %s does not match filter module %s", resource, f.Module)
+	}
+
+	keys := []IndexKey{}
+	for index, step := range pattern {
+		if step.Key.IsAny() {
+			keys = append(keys, module[index].Key)
+		}
+	}
+
+	steps, err := parseTraversal(target)
+	if err != nil {
+		return "", err
+	}
+	expanded := make([]string, 0, len(steps))
+	for _, step := range steps {
+		if step.key.IsAny() {
+			if len(keys) == 0 {
+				return "", fmt.Errorf("target %s has more [*] keys than source location", target)
 			}
+			step.key, keys = keys[0], keys[1:]
 		}
+		expanded = append(expanded, step.String())
+	}
 
+	return strings.Join(expanded, "."), nil
+}
+
+// Target returns the address where resource must be moved when the resources selected by
+// the filter are moved to newLocation. newLocation is a resource address, or a module
+// address when the filter selects a module subtree. Wildcards of newLocation are expanded
+// with ExpandTarget.
+func (f ResourceFilter) Target(resource TerraformResource, newLocation string) (Address, error) {
+	expanded, err := f.ExpandTarget(resource, newLocation)
+	if err != nil {
+		return Address{}, err
 	}
 
-	return &output, nil
+	if !f.ModuleSubtree {
+		target, err := ParseAddress(expanded)
+		if err != nil {
+			return Address{}, err
+		}
+		if !target.Key.IsNone() {
+			return Address{}, fmt.Errorf("invalid address %q: instance keys are taken from the resource instances", newLocation)
+		}
+		return target, nil
+	}
+
+	newModule, err := ParseModuleAddress(expanded)
+	if err != nil {
+		return Address{}, err
+	}
+	pattern, err := ParseModulePath(f.Module)
+	if err != nil {
+		return Address{}, err
+	}
+
+	target := resource.Address(NoKey)
+	module := append(ModulePath{}, newModule...)
+	if length := target.Module.PrefixLength(pattern); length >= 0 {
+		module = append(module, target.Module[length:]...)
+	}
+	target.Module = module
+	return target, nil
+}
+
+// ValidateTarget checks that newLocation is a valid target for the resources selected by
+// the filter, without requiring any resource.
+func (f ResourceFilter) ValidateTarget(newLocation string) error {
+	pattern, err := ParseModulePath(f.Module)
+	if err != nil {
+		return err
+	}
+
+	module := make(ModulePath, 0, len(pattern))
+	for _, step := range pattern {
+		if step.Name == "**" {
+			continue
+		}
+		if step.Key.IsAny() {
+			step.Key = IntKey(0)
+		}
+		step.Name = strings.ReplaceAll(step.Name, "*", "x")
+		module = append(module, step)
+	}
+
+	if _, err = f.Target(TerraformResource{Module: module.String(), Mode: ManagedMode, Type: "type", Name: "name"}, newLocation); err != nil {
+		if f.ModuleSubtree {
+			return fmt.Errorf("invalid target %q: expected a module address such as module.name", newLocation)
+		}
+		return fmt.Errorf("invalid target %q: expected a resource address such as [module.name.]type.name", newLocation)
+	}
+	return nil
+}
+
+// FilterSet combines several filters. A resource is selected when it matches at least one of
+// the Include filters, or when there is no Include filter, and none of the Exclude filters.
+type FilterSet struct {
+	Include []ResourceFilter
+	Exclude []ResourceFilter
+}
+
+// NewFilterSet creates a FilterSet from filter strings accepted by CreateResourceFilterFromString
+func NewFilterSet(includes []string, excludes []string) (*FilterSet, error) {
+	set := FilterSet{}
+	for _, include := range includes {
+		filter, err := CreateResourceFilterFromString(include)
+		if err != nil {
+			return nil, fmt.Errorf("filter %q: %w", include, err)
+		}
+		set.Include = append(set.Include, *filter)
+	}
+	for _, exclude := range excludes {
+		if strings.TrimSpace(exclude) == "" {
+			return nil, errors.New("exclude filter must not be empty")
+		}
+		filter, err := CreateResourceFilterFromString(exclude)
+		if err != nil {
+			return nil, fmt.Errorf("exclude %q: %w", exclude, err)
+		}
+		set.Exclude = append(set.Exclude, *filter)
+	}
+	return &set, nil
+}
+
+// Matches returns true if the resource matches one of the included filters and none of the
+// excluded filters
+func (s FilterSet) Matches(resource TerraformResource) bool {
+	for _, exclude := range s.Exclude {
+		if exclude.Matches(resource) {
+			return false
+		}
+	}
+
+	if len(s.Include) == 0 {
+		return true
+	}
+	for _, include := range s.Include {
+		if include.Matches(resource) {
+			return true
+		}
+	}
+	return false
 }
@@ -198,3 +198,241 @@ func TestCreateResourceFilterFromString(t *testing.T) {
 		assert.Equal(t, expected, *output, "Resource filter should be not nil if pattern is type.name or module.module_name.type.name")
 	})
 }
+
+func TestCreateResourceFilterFromStringWithNestedModules(t *testing.T) {
+	t.Run("Nested module resource address should returns a valid ResourceFilter", func(t *testing.T) {
+		input := "module.network.module.subnets.aws_subnet.private"
+		expected := state.ResourceFilter{
+			Module: "module.network.module.subnets",
+			Type:   "aws_subnet",
+			Name:   "private",
+		}
+		output, err := state.CreateResourceFilterFromString(input)
+		assert.Nil(t, err, "Error should be nil")
+		assert.Equal(t, expected, *output)
+	})
+
+	t.Run("Module address should returns a ResourceFilter on the module subtree", func(t *testing.T) {
+		input := "module.network.module.subnets"
+		expected := state.ResourceFilter{
+			Module:        "module.network.module.subnets",
+			ModuleSubtree: true,
+		}
+		output, err := state.CreateResourceFilterFromString(input)
+		assert.Nil(t, err, "Error should be nil")
+		assert.Equal(t, expected, *output)
+	})
+
+	t.Run("Incomplete nested module address should returns an error", func(t *testing.T) {
+		for _, input := range []string{"module", "module.network.module", "module.network.module..type.name", "module.network.module.subnets.type"} {
+			output, err := state.CreateResourceFilterFromString(input)
+			assert.NotNil(t, err, input)
+			assert.Nil(t, output, input)
+		}
+	})
+}
+
+func TestThatFilterMatchesModuleSubtree(t *testing.T) {
+	filter := state.ResourceFilter{Module: "module.network", ModuleSubtree: true}
+
+	assert.True(t, filter.Matches(state.TerraformResource{Module: "module.network", Type: "aws_vpc", Name: "main"}))
+	assert.True(t, filter.Matches(state.TerraformResource{Module: "module.network.module.subnets", Type: "aws_subnet", Name: "private"}))
+	assert.False(t, filter.Matches(state.TerraformResource{Module: "module.network_legacy", Type: "aws_vpc", Name: "main"}))
+	assert.False(t, filter.Matches(state.TerraformResource{Type: "aws_vpc", Name: "main"}))
+
+	t.Run("Without subtree, nested modules should not match", func(t *testing.T) {
+		filter := state.ResourceFilter{Module: "module.network"}
+		assert.False(t, filter.Matches(state.TerraformResource{Module: "module.network.module.subnets", Type: "aws_subnet", Name: "private"}))
+	})
+}
+
+func TestFilterWithModuleInstanceKeys(t *testing.T) {
+	eu := state.TerraformResource{Module: `module.app["eu"]`, Type: "aws_s3_bucket", Name: "logs"}
+	us := state.TerraformResource{Module: `module.app["us"]`, Type: "aws_s3_bucket", Name: "logs"}
+
+	t.Run("Module instance key should be part of the filter", func(t *testing.T) {
+		filter, err := state.CreateResourceFilterFromString(`module.app["eu"].aws_s3_bucket.logs`)
+		assert.Nil(t, err)
+		assert.Equal(t, state.ResourceFilter{Module: `module.app["eu"]`, Type: "aws_s3_bucket", Name: "logs"}, *filter)
+		assert.True(t, filter.Matches(eu))
+		assert.False(t, filter.Matches(us))
+	})
+
+	t.Run("Wildcard key should select every module instance", func(t *testing.T) {
+		filter, err := state.CreateResourceFilterFromString(`module.app[*].aws_s3_bucket.logs`)
+		assert.Nil(t, err)
+		assert.True(t, filter.Matches(eu))
+		assert.True(t, filter.Matches(us))
+		assert.False(t, filter.Matches(state.TerraformResource{Module: `module.web["eu"]`, Type: "aws_s3_bucket", Name: "logs"}))
+	})
+
+	t.Run("Module glob should select every module instance", func(t *testing.T) {
+		filter, err := state.CreateResourceFilterFromString(`module.*.aws_s3_bucket.logs`)
+		assert.Nil(t, err)
+		assert.True(t, filter.Matches(eu))
+		assert.True(t, filter.Matches(state.TerraformResource{Module: "module.web[0]", Type: "aws_s3_bucket", Name: "logs"}))
+		assert.True(t, filter.Matches(state.TerraformResource{Module: "module.web", Type: "aws_s3_bucket", Name: "logs"}))
+	})
+
+	t.Run("Instance keys on resources should returns an error", func(t *testing.T) {
+		output, err := state.CreateResourceFilterFromString(`module.app[*].aws_s3_bucket.logs[0]`)
+		assert.NotNil(t, err)
+		assert.Nil(t, output)
+	})
+}
+
+func TestResourceFilter_ExpandTarget(t *testing.T) {
+	resource := state.TerraformResource{Module: `module.app["eu"].module.db[1]`, Type: "aws_db_instance", Name: "main"}
+	filter := state.ResourceFilter{Module: `module.app[*].module.db[*]`}
+
+	t.Run("Target without wildcard should be returned as is", func(t *testing.T) {
+		target, err := filter.ExpandTarget(resource, "aws_db_instance.main")
+		assert.Nil(t, err)
+		assert.Equal(t, "aws_db_instance.main", target)
+	})
+
+	t.Run("Wildcards should be replaced by the source keys in order", func(t *testing.T) {
+		target, err := filter.ExpandTarget(resource, `module.storage[*].module.rds[*].aws_db_instance.main`)
+		assert.Nil(t, err)
+		assert.Equal(t, `module.storage["eu"].module.rds[1].aws_db_instance.main`, target)
+	})
+
+	t.Run("Target with more wildcards than source should returns an error", func(t *testing.T) {
+		_, err := state.ResourceFilter{Module: `module.app[*]`}.ExpandTarget(resource, `module.a[*].module.b[*].aws_db_instance.main`)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestResourceFilter_Target(t *testing.T) {
+	resource := state.TerraformResource{Module: `module.app["eu"].module.db`, Mode: state.ManagedMode, Type: "aws_db_instance", Name: "main"}
+
+	t.Run("Resource target should be parsed", func(t *testing.T) {
+		filter := state.ResourceFilter{Module: `module.app[*].module.db`, Type: "aws_db_instance", Name: "main"}
+		target, err := filter.Target(resource, `module.rds[*].aws_db_instance.primary`)
+		assert.Nil(t, err)
+		assert.Equal(t, `module.rds["eu"].aws_db_instance.primary`, target.String())
+	})
+
+	t.Run("Module target should relocate nested modules", func(t *testing.T) {
+		filter := state.ResourceFilter{Module: `module.app[*]`, ModuleSubtree: true}
+		target, err := filter.Target(resource, `module.eu`)
+		assert.Nil(t, err)
+		assert.Equal(t, `module.eu.module.db.aws_db_instance.main`, target.String())
+	})
+
+	t.Run("Invalid targets should returns an error", func(t *testing.T) {
+		filter := state.ResourceFilter{Type: "aws_db_instance", Name: "main"}
+		assert.NotNil(t, filter.ValidateTarget("aws_db_instance"))
+		assert.NotNil(t, filter.ValidateTarget("aws_db_instance.main[0]"))
+		assert.NotNil(t, filter.ValidateTarget("module.app[*].aws_db_instance.main"))
+		assert.Nil(t, filter.ValidateTarget("module.app.aws_db_instance.main"))
+
+		subtree := state.ResourceFilter{Module: `module.app[*]`, ModuleSubtree: true}
+		assert.NotNil(t, subtree.ValidateTarget("module.app.aws_db_instance.main"))
+		assert.Nil(t, subtree.ValidateTarget("module.apps[*]"))
+	})
+}
+
+func TestFilterWithGlobs(t *testing.T) {
+	role := state.TerraformResource{Module: "module.team_payments", Type: "aws_iam_role", Name: "deployer"}
+	nestedRole := state.TerraformResource{Module: "module.team_core.module.ci", Type: "aws_iam_role", Name: "runner"}
+	keyedRole := state.TerraformResource{Module: `module.team_data["eu"]`, Type: "aws_iam_role", Name: "reader"}
+	bucket := state.TerraformResource{Type: "aws_s3_bucket_policy", Name: "logs"}
+
+	t.Run("Glob on module name should select every module", func(t *testing.T) {
+		filter, err := state.CreateResourceFilterFromString("module.*.aws_iam_role.*")
+		assert.Nil(t, err)
+		assert.True(t, filter.Matches(role))
+		assert.False(t, filter.Matches(nestedRole))
+		assert.True(t, filter.Matches(keyedRole))
+		assert.False(t, filter.Matches(bucket))
+	})
+
+	t.Run("Glob with wildcard key should select every module instance", func(t *testing.T) {
+		filter, err := state.CreateResourceFilterFromString("module.team_*[*].aws_iam_role.*")
+		assert.Nil(t, err)
+		assert.True(t, filter.Matches(role))
+		assert.True(t, filter.Matches(keyedRole))
+		assert.False(t, filter.Matches(nestedRole))
+	})
+
+	t.Run("Glob on resource type should select matching types", func(t *testing.T) {
+		filter, err := state.CreateResourceFilterFromString("aws_s3_bucket_*.logs")
+		assert.Nil(t, err)
+		assert.True(t, filter.Matches(bucket))
+		assert.False(t, filter.Matches(state.TerraformResource{Type: "aws_s3_bucket", Name: "logs"}))
+	})
+
+	t.Run("Recursive glob should select nested modules at any depth", func(t *testing.T) {
+		filter, err := state.CreateResourceFilterFromString("module.team_**")
+		assert.Nil(t, err)
+		assert.True(t, filter.Matches(role))
+		assert.True(t, filter.Matches(nestedRole))
+		assert.True(t, filter.Matches(keyedRole))
+		assert.False(t, filter.Matches(bucket))
+
+		filter, err = state.CreateResourceFilterFromString("module.**.aws_iam_role.runner")
+		assert.Nil(t, err)
+		assert.True(t, filter.Matches(nestedRole))
+		assert.False(t, filter.Matches(role))
+	})
+
+	t.Run("Recursive glob source should forbid target wildcards", func(t *testing.T) {
+		filter := state.ResourceFilter{Module: "module.team_**", ModuleSubtree: true}
+		_, err := filter.ExpandTarget(nestedRole, "module.teams[*]")
+		assert.NotNil(t, err)
+
+		target, err := filter.Target(nestedRole, "module.teams")
+		assert.Nil(t, err)
+		assert.Equal(t, "module.teams.module.ci.aws_iam_role.runner", target.String())
+	})
+}
+
+func TestFilterSet(t *testing.T) {
+	key := state.TerraformResource{Module: "module.core", Mode: state.ManagedMode, Type: "aws_kms_key", Name: "main"}
+	bucket := state.TerraformResource{Module: "module.core", Mode: state.ManagedMode, Type: "aws_s3_bucket", Name: "logs"}
+	identity := state.TerraformResource{Module: "module.core", Mode: state.DataMode, Type: "aws_caller_identity", Name: "current"}
+	role := state.TerraformResource{Module: "module.iam", Mode: state.ManagedMode, Type: "aws_iam_role", Name: "admin"}
+	queue := state.TerraformResource{Mode: state.ManagedMode, Type: "aws_sqs_queue", Name: "jobs"}
+
+	t.Run("Empty filter set should select every resource", func(t *testing.T) {
+		set, err := state.NewFilterSet(nil, nil)
+		assert.Nil(t, err)
+		for _, resource := range []state.TerraformResource{key, bucket, identity, role, queue} {
+			assert.True(t, set.Matches(resource))
+		}
+	})
+
+	t.Run("Resources matching any included filter should be selected", func(t *testing.T) {
+		set, err := state.NewFilterSet([]string{"module.iam", "aws_sqs_queue.*"}, nil)
+		assert.Nil(t, err)
+		assert.True(t, set.Matches(role))
+		assert.True(t, set.Matches(queue))
+		assert.False(t, set.Matches(bucket))
+	})
+
+	t.Run("Resources matching any excluded filter should not be selected", func(t *testing.T) {
+		set, err := state.NewFilterSet([]string{"module.core"}, []string{"aws_kms_key.*", "data.*.*"})
+		assert.Nil(t, err)
+		assert.True(t, set.Matches(bucket))
+		assert.False(t, set.Matches(key))
+		assert.False(t, set.Matches(identity))
+		assert.False(t, set.Matches(role))
+	})
+
+	t.Run("Excluded filters alone should select every other resource", func(t *testing.T) {
+		set, err := state.NewFilterSet(nil, []string{"module.core"})
+		assert.Nil(t, err)
+		assert.False(t, set.Matches(bucket))
+		assert.True(t, set.Matches(role))
+		assert.True(t, set.Matches(queue))
+	})
+
+	t.Run("Invalid filters should returns an error", func(t *testing.T) {
+		_, err := state.NewFilterSet([]string{"module."}, nil)
+		assert.NotNil(t, err)
+
+		_, err = state.NewFilterSet(nil, []string{""})
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,198 @@
+// Package state list all related resource to terraform state file
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Output formats of moves
+const (
+	// FormatMovedBlocks renders moves as terraform moved blocks
+	FormatMovedBlocks = "moved"
+	// FormatStateMv renders moves as a shell script of terraform state mv commands
+	FormatStateMv = "state-mv"
+)
+
+// MoveFormatter renders a list of moves
+type MoveFormatter interface {
+	Format(moves []Move) string
+}
+
+// NewMoveFormatter returns the formatter of the given format. statePath and stateOutPath are
+// only used by the state-mv format, to give -state and -state-out to terraform.
+func NewMoveFormatter(format string, statePath string, stateOutPath string) (MoveFormatter, error) {
+	switch format {
+	case "", FormatMovedBlocks:
+		if statePath != "" || stateOutPath != "" {
+			return nil, fmt.Errorf("state paths can only be given with the %s format", FormatStateMv)
+		}
+		return MovedBlocks{}, nil
+	case FormatStateMv:
+		return StateMvScript{State: statePath, StateOut: stateOutPath}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q: expected %s or %s", format, FormatMovedBlocks, FormatStateMv)
+	}
+}
+
+// MovedBlocks renders moves as terraform moved blocks separated by empty lines
+type MovedBlocks struct{}
+
+// Format renders moves as terraform moved blocks
+func (f MovedBlocks) Format(moves []Move) string {
+	var builder strings.Builder
+	for _, move := range moves {
+		builder.WriteString(move.String())
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// StateMvScript renders moves as a shell script running terraform state mv. Chained moves
+// (a to b, b to c) are collapsed like terraform does with moved blocks, so that a and b are
+// both moved to c, the first of them found in the state being moved. The script is idempotent:
+// moves whose target is found in the state are skipped. Addresses are compared with the lines
+// of terraform state list, as it also lists aws_instance.a[0] for aws_instance.a: an address
+// without key only stands for all the instances of a resource or module call when no address
+// of the move has a key. State and StateOut are the optional paths given to terraform with
+// -state and -state-out, to move resources between local state files.
+type StateMvScript struct {
+	State    string
+	StateOut string
+}
+
+// stateMv moves the first of its sources found in the state to its target
+type stateMv struct {
+	Target  Address
+	Sources []Address
+	Scope   string
+}
+
+// Format renders moves as a shell script
+func (f StateMvScript) Format(moves []Move) string {
+	sourceArgs := ""
+	targetArgs := ""
+	moveArgs := ""
+	if f.State != "" {
+		sourceArgs = " " + shellQuote("-state="+f.State)
+		targetArgs = sourceArgs
+		moveArgs = sourceArgs
+	}
+	if f.StateOut != "" {
+		targetArgs = " " + shellQuote("-state="+f.StateOut)
+		moveArgs += " " + shellQuote("-state-out="+f.StateOut)
+	}
+
+	var builder strings.Builder
+	builder.WriteString("#!/bin/sh\n")
+	builder.WriteString("# Moves resources with terraform state mv. Moves already applied are skipped,\n")
+	builder.WriteString("# so the script can safely be run again.\n")
+	builder.WriteString("set -eu\n\n")
+	builder.WriteString("# listed succeeds when terraform state list, given the remaining arguments, lists the\n")
+	builder.WriteString("# instance $2, or any instance of the resource or module call $2 when $1 is all.\n")
+	builder.WriteString("listed() {\n")
+	builder.WriteString("  scope=\"$1\"\n")
+	builder.WriteString("  address=\"$2\"\n")
+	builder.WriteString("  shift 2\n")
+	builder.WriteString("  terraform state list \"$@\" \"$address\" 2>/dev/null | {\n")
+	builder.WriteString("    while IFS= read -r line; do\n")
+	builder.WriteString("      case \"$line\" in\n")
+	builder.WriteString("        \"$address\" | \"$address\".*) exit 0 ;;\n")
+	builder.WriteString("        \"$address\"\\[*) [ \"$scope\" = all ] && exit 0 ;;\n")
+	builder.WriteString("      esac\n")
+	builder.WriteString("    done\n")
+	builder.WriteString("    exit 1\n")
+	builder.WriteString("  }\n")
+	builder.WriteString("}\n\n")
+	builder.WriteString("# state_mv moves the first of the sources, given after the scope $1 and the target $2,\n")
+	builder.WriteString("# found in the state to the target, unless the target is already in the state.\n")
+	builder.WriteString("state_mv() {\n")
+	builder.WriteString("  mv_scope=\"$1\"\n")
+	builder.WriteString("  target=\"$2\"\n")
+	builder.WriteString("  shift 2\n")
+	fmt.Fprintf(&builder, "  if listed \"$mv_scope\" \"$target\"%s; then\n", targetArgs)
+	builder.WriteString("    echo \"$* already moved to $target, skipping\"\n")
+	builder.WriteString("    return\n")
+	builder.WriteString("  fi\n")
+	builder.WriteString("  for source in \"$@\"; do\n")
+	fmt.Fprintf(&builder, "    if listed \"$mv_scope\" \"$source\"%s; then\n", sourceArgs)
+	fmt.Fprintf(&builder, "      terraform state mv%s \"$source\" \"$target\"\n", moveArgs)
+	builder.WriteString("      return\n")
+	builder.WriteString("    fi\n")
+	builder.WriteString("  done\n")
+	builder.WriteString("  echo \"$* not found in terraform state\" >&2\n")
+	builder.WriteString("  exit 1\n")
+	builder.WriteString("}\n\n")
+	for _, mv := range collapseMoves(moves) {
+		fmt.Fprintf(&builder, "state_mv %s %s", mv.Scope, shellQuote(mv.Target.String()))
+		for _, source := range mv.Sources {
+			fmt.Fprintf(&builder, " %s", shellQuote(source.String()))
+		}
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// collapseMoves groups moves by the address their source ends at once chained moves are
+// followed, in the order of the first move of each group
+func collapseMoves(moves []Move) []stateMv {
+	collapsed := []stateMv{}
+	positions := map[string]int{}
+	for _, move := range moves {
+		target, ok := movedAddress(move.To, moves)
+		if !ok {
+			target = move.To
+		}
+
+		position, found := positions[target.String()]
+		if !found {
+			position = len(collapsed)
+			positions[target.String()] = position
+			collapsed = append(collapsed, stateMv{Target: target, Scope: "all"})
+		}
+		collapsed[position].Sources = append(collapsed[position].Sources, move.From)
+		if !lastKey(move.From).IsNone() || !lastKey(target).IsNone() {
+			collapsed[position].Scope = "instance"
+		}
+	}
+	return collapsed
+}
+
+// lastKey returns the key of the resource instance, or of the module instance, at address
+func lastKey(address Address) IndexKey {
+	if address.IsModule() {
+		if address.Module.IsRoot() {
+			return NoKey
+		}
+		return address.Module[len(address.Module)-1].Key
+	}
+	return address.Key
+}
+
+// shellQuote quotes value for a POSIX shell, between single quotes
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
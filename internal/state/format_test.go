@@ -0,0 +1,120 @@
+/*
+MIT License
+
+# Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMoveFormatter(t *testing.T) {
+	moves := func(t *testing.T) []state.Move {
+		return []state.Move{{From: mustAddress(t, `aws_s3_bucket.logs["it's"]`), To: mustAddress(t, `module.storage.aws_s3_bucket.logs["it's"]`)}}
+	}
+
+	t.Run("Moved blocks should be the default format", func(t *testing.T) {
+		formatter, err := state.NewMoveFormatter("", "", "")
+		assert.Nil(t, err)
+		assert.Equal(t, "moved {\n  from = aws_s3_bucket.logs[\"it's\"]\n  to   = module.storage.aws_s3_bucket.logs[\"it's\"]\n}\n\n", formatter.Format(moves(t)))
+	})
+
+	t.Run("State mv script should quote addresses", func(t *testing.T) {
+		formatter, err := state.NewMoveFormatter(state.FormatStateMv, "", "")
+		assert.Nil(t, err)
+
+		script := formatter.Format(moves(t))
+		assert.Contains(t, script, "#!/bin/sh\n")
+		assert.Contains(t, script, `terraform state mv "$source" "$target"`)
+		assert.Contains(t, script, `state_mv instance 'module.storage.aws_s3_bucket.logs["it'\''s"]' 'aws_s3_bucket.logs["it'\''s"]'`)
+	})
+
+	t.Run("State mv script should use given state files", func(t *testing.T) {
+		formatter, err := state.NewMoveFormatter(state.FormatStateMv, "old.tfstate", "new.tfstate")
+		assert.Nil(t, err)
+
+		script := formatter.Format(moves(t))
+		assert.Contains(t, script, `listed "$mv_scope" "$source" '-state=old.tfstate'`)
+		assert.Contains(t, script, `terraform state mv '-state=old.tfstate' '-state-out=new.tfstate' "$source" "$target"`)
+		assert.Contains(t, script, `listed "$mv_scope" "$target" '-state=new.tfstate'`)
+	})
+
+	t.Run("State mv script should collapse chained moves", func(t *testing.T) {
+		formatter, err := state.NewMoveFormatter(state.FormatStateMv, "", "")
+		assert.Nil(t, err)
+
+		script := formatter.Format([]state.Move{
+			{From: mustAddress(t, "aws_instance.a"), To: mustAddress(t, "aws_instance.b")},
+			{From: mustAddress(t, "aws_instance.b"), To: mustAddress(t, "aws_instance.c")},
+			{From: mustAddress(t, "aws_instance.c"), To: mustAddress(t, "module.app.aws_instance.c")},
+			{From: mustAddress(t, "aws_instance.x"), To: mustAddress(t, "aws_instance.y")},
+		})
+		assert.Contains(t, script, "\nstate_mv all 'module.app.aws_instance.c' 'aws_instance.a' 'aws_instance.b' 'aws_instance.c'\n"+
+			"state_mv all 'aws_instance.y' 'aws_instance.x'\n")
+	})
+
+	t.Run("State mv script should move chained resources where moved blocks do", func(t *testing.T) {
+		moves := []state.Move{
+			{From: mustAddress(t, "null_resource.a"), To: mustAddress(t, "null_resource.b")},
+			{From: mustAddress(t, "null_resource.b"), To: mustAddress(t, "null_resource.c")},
+		}
+		for _, name := range []string{"a", "b"} {
+			nulls := state.TerraformState{Version: 4, Resources: []state.TerraformResource{
+				{Mode: state.ManagedMode, Type: "null_resource", Name: name, Instances: []state.TerraformResourceValue{{}}},
+			}}
+			moved, err := nulls.ApplyMoves(moves)
+			assert.Nil(t, err)
+
+			script := state.StateMvScript{}.Format(moves)
+			line := script[strings.LastIndex(script, "\nstate_mv ")+1:]
+			fields := strings.Fields(strings.ReplaceAll(line, "'", ""))
+			assert.Equal(t, moved.Resources[0].String(), fields[2])
+			assert.Contains(t, fields[3:], "null_resource."+name)
+		}
+	})
+
+	t.Run("State mv script should compare instances exactly when a key is moved", func(t *testing.T) {
+		formatter, err := state.NewMoveFormatter(state.FormatStateMv, "", "")
+		assert.Nil(t, err)
+
+		script := formatter.Format([]state.Move{
+			{From: mustAddress(t, "aws_instance.a"), To: mustAddress(t, "aws_instance.a[0]")},
+			{From: mustModuleAddress(t, "module.app"), To: mustModuleAddress(t, `module.app["eu"]`)},
+			{From: mustModuleAddress(t, "module.db"), To: mustModuleAddress(t, "module.storage")},
+		})
+		assert.Contains(t, script, `if listed "$mv_scope" "$target"; then`)
+		assert.Contains(t, script, "state_mv instance 'aws_instance.a[0]' 'aws_instance.a'\n")
+		assert.Contains(t, script, `state_mv instance 'module.app["eu"]' 'module.app'`+"\n")
+		assert.Contains(t, script, "state_mv all 'module.storage' 'module.db'\n")
+	})
+
+	t.Run("Invalid options should returns an error", func(t *testing.T) {
+		_, err := state.NewMoveFormatter("hcl", "", "")
+		assert.NotNil(t, err)
+		_, err = state.NewMoveFormatter(state.FormatMovedBlocks, "old.tfstate", "")
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,162 @@
+// Package state list all related resource to terraform state file
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultImportIDTemplates gives the import ID of resource types which can not be imported
+// with their id attribute. Templates are evaluated like for_each key templates.
+var DefaultImportIDTemplates = map[string]string{
+	"aws_route53_record":              `{{ .attributes.zone_id }}_{{ .attributes.name }}_{{ .attributes.type }}{{ with .attributes.set_identifier }}_{{ . }}{{ end }}`,
+	"aws_iam_role_policy_attachment":  `{{ .attributes.role }}/{{ .attributes.policy_arn }}`,
+	"aws_iam_user_policy_attachment":  `{{ .attributes.user }}/{{ .attributes.policy_arn }}`,
+	"aws_iam_group_policy_attachment": `{{ .attributes.group }}/{{ .attributes.policy_arn }}`,
+	"aws_route_table_association":     `{{ if .attributes.subnet_id }}{{ .attributes.subnet_id }}{{ else }}{{ .attributes.gateway_id }}{{ end }}/{{ .attributes.route_table_id }}`,
+	"aws_lambda_permission":           `{{ .attributes.function_name }}/{{ .attributes.statement_id }}`,
+	"google_project_iam_member":       `{{ .attributes.project }} {{ .attributes.role }} {{ .attributes.member }}`,
+	"google_project_iam_binding":      `{{ .attributes.project }} {{ .attributes.role }}`,
+}
+
+// Import is a terraform import block, importing an existing object at a resource instance address
+type Import struct {
+	To Address
+	ID string
+}
+
+// String renders the import as a terraform import block
+func (i Import) String() string {
+	return fmt.Sprintf("import {\n  to = %s\n  id = %s\n}\n", i.To, quoteString(i.ID))
+}
+
+// FormatImports renders imports as terraform import blocks separated by blank lines
+func FormatImports(imports []Import) string {
+	blocks := make([]string, 0, len(imports))
+	for _, block := range imports {
+		blocks = append(blocks, block.String())
+	}
+	return strings.Join(blocks, "\n")
+}
+
+// ImportIDs computes the import ID of resource instances, either with the template of their
+// resource type or from their id attribute
+type ImportIDs struct {
+	Templates map[string]*template.Template
+}
+
+// NewImportIDs parses DefaultImportIDTemplates, extended or overridden by templates
+func NewImportIDs(templates map[string]string) (*ImportIDs, error) {
+	ids := ImportIDs{Templates: map[string]*template.Template{}}
+	for _, source := range []map[string]string{DefaultImportIDTemplates, templates} {
+		for resourceType, text := range source {
+			parsed, err := parseInstanceTemplate("import ID", text)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", resourceType, err)
+			}
+			ids.Templates[resourceType] = parsed
+		}
+	}
+	return &ids, nil
+}
+
+// ImportIDTemplatesFromFile reads a YAML file mapping resource types to import ID templates:
+//
+//	aws_iam_user_group_membership: '{{ .attributes.user }}/{{ index .attributes.groups 0 }}'
+func ImportIDTemplatesFromFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	templates := map[string]string{}
+	if err := yaml.Unmarshal(data, &templates); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return templates, nil
+}
+
+// LoadImportIDs returns the import IDs of DefaultImportIDTemplates, extended or overridden by
+// the templates of the YAML file at path when it is not empty
+func LoadImportIDs(path string) (*ImportIDs, error) {
+	templates := map[string]string{}
+	if path != "" {
+		var err error
+		templates, err = ImportIDTemplatesFromFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return NewImportIDs(templates)
+}
+
+// ID returns the import ID of a resource instance
+func (i ImportIDs) ID(resource TerraformResource, instance TerraformResourceValue) (string, error) {
+	if parsed, found := i.Templates[resource.Type]; found {
+		return executeInstanceTemplate(parsed, resource, instance)
+	}
+
+	id, found := instance.Attributes["id"]
+	if !found || id == nil || fmt.Sprint(id) == "" {
+		return "", fmt.Errorf("instance %s has no id attribute, an import ID template is required for %s", resource.Address(instance.IndexKey), resource.Type)
+	}
+	return fmt.Sprint(id), nil
+}
+
+// Imports returns the import blocks of the managed resource instances selected by filter.
+// Every instance without import ID is reported in the returned error.
+func (s TerraformState) Imports(filter Matcher, ids ImportIDs) ([]Import, error) {
+	imports := []Import{}
+	problems := []string{}
+	for _, resource := range s.ListResources(filter) {
+		if resource.Mode != ManagedMode {
+			continue
+		}
+		for _, instance := range resource.Instances {
+			if instance.Deposed != "" {
+				continue
+			}
+			id, err := ids.ID(resource, instance)
+			if err != nil {
+				problems = append(problems, err.Error())
+				continue
+			}
+			imports = append(imports, Import{To: resource.Address(instance.IndexKey), ID: id})
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, errors.New(strings.Join(problems, "\n"))
+	}
+	return imports, nil
+}
@@ -0,0 +1,110 @@
+/*
+MIT License
+
+# Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func importsState() state.TerraformState {
+	return state.TerraformState{
+		Version: 4,
+		Resources: []state.TerraformResource{
+			{Mode: state.ManagedMode, Type: "aws_instance", Name: "web", Instances: []state.TerraformResourceValue{
+				{IndexKey: state.IntKey(0), Attributes: map[string]interface{}{"id": "i-1"}},
+				{IndexKey: state.IntKey(0), Deposed: "00000001", Attributes: map[string]interface{}{"id": "i-0"}},
+			}},
+			{Mode: state.DataMode, Type: "aws_vpc", Name: "main", Instances: []state.TerraformResourceValue{
+				{Attributes: map[string]interface{}{"id": "vpc-1"}},
+			}},
+			{Module: "module.dns", Mode: state.ManagedMode, Type: "aws_route53_record", Name: "www", Instances: []state.TerraformResourceValue{
+				{Attributes: map[string]interface{}{"id": "Z1_www_A", "zone_id": "Z1", "name": "www", "type": "A", "set_identifier": ""}},
+			}},
+			{Module: "module.iam", Mode: state.ManagedMode, Type: "aws_iam_role_policy_attachment", Name: "admin", Instances: []state.TerraformResourceValue{
+				{Attributes: map[string]interface{}{"id": "admin-2022", "role": "admin", "policy_arn": "arn:aws:iam::aws:policy/AdministratorAccess"}},
+			}},
+		},
+	}
+}
+
+func TestImports(t *testing.T) {
+	t.Run("Imports should use id attribute or import ID templates", func(t *testing.T) {
+		ids, err := state.NewImportIDs(nil)
+		assert.Nil(t, err)
+
+		imports, err := importsState().Imports(state.MatchAll(), *ids)
+		assert.Nil(t, err)
+		assert.Len(t, imports, 3)
+		assert.Equal(t, "import {\n  to = aws_instance.web[0]\n  id = \"i-1\"\n}\n", imports[0].String())
+		assert.Equal(t, "Z1_www_A", imports[1].ID)
+		assert.Equal(t, "admin/arn:aws:iam::aws:policy/AdministratorAccess", imports[2].ID)
+	})
+
+	t.Run("Imports should use templates given by users", func(t *testing.T) {
+		ids, err := state.NewImportIDs(map[string]string{"aws_instance": "{{ .name }}-{{ .attributes.id }}"})
+		assert.Nil(t, err)
+		filter, err := state.CreateResourceFilterFromString("aws_instance.web")
+		assert.Nil(t, err)
+
+		imports, err := importsState().Imports(filter, *ids)
+		assert.Nil(t, err)
+		assert.Len(t, imports, 1)
+		assert.Equal(t, "web-i-1", imports[0].ID)
+	})
+
+	t.Run("Imports should returns an error when an instance has no import ID", func(t *testing.T) {
+		ids, err := state.NewImportIDs(map[string]string{"aws_route53_record": "{{ .attributes.fqdn }}"})
+		assert.Nil(t, err)
+
+		_, err = importsState().Imports(state.MatchAll(), *ids)
+		assert.ErrorContains(t, err, "module.dns.aws_route53_record.www")
+	})
+
+	t.Run("NewImportIDs should returns an error when a template is invalid", func(t *testing.T) {
+		_, err := state.NewImportIDs(map[string]string{"aws_instance": "{{ .attributes.id"})
+		assert.ErrorContains(t, err, "aws_instance")
+	})
+
+	t.Run("LoadImportIDs should read templates from a file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "import-ids.yaml")
+		assert.Nil(t, os.WriteFile(path, []byte("aws_instance: '{{ .name }}-{{ .attributes.id }}'\n"), 0o600))
+
+		ids, err := state.LoadImportIDs(path)
+		assert.Nil(t, err)
+		assert.Contains(t, ids.Templates, "aws_instance")
+		assert.Contains(t, ids.Templates, "aws_route53_record")
+
+		ids, err = state.LoadImportIDs("")
+		assert.Nil(t, err)
+		assert.NotContains(t, ids.Templates, "aws_instance")
+
+		_, err = state.LoadImportIDs(filepath.Join(t.TempDir(), "missing.yaml"))
+		assert.NotNil(t, err)
+	})
+}
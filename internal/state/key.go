@@ -0,0 +1,156 @@
+// Package state list all related resource to terraform state file
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+type indexKeyKind int
+
+const (
+	noKey indexKeyKind = iota
+	intKey
+	stringKey
+	anyKey
+)
+
+// IndexKey represents the key of a resource instance. Resources created with count
+// are indexed by a number whereas resources created with for_each are indexed by a string.
+// The zero value represents a resource without any index key.
+type IndexKey struct {
+	kind   indexKeyKind
+	number int
+	text   string
+}
+
+// NoKey is the index key of a resource instance created without count nor for_each
+var NoKey = IndexKey{}
+
+// AnyKey is a wildcard key written [*] matching every instance key. It is only
+// meaningful in filters and refactoring targets.
+var AnyKey = IndexKey{kind: anyKey}
+
+// IntKey creates a numeric index key as used by count
+func IntKey(value int) IndexKey {
+	return IndexKey{kind: intKey, number: value}
+}
+
+// StringKey creates a string index key as used by for_each
+func StringKey(value string) IndexKey {
+	return IndexKey{kind: stringKey, text: value}
+}
+
+// IsNone returns true if the instance has no index key
+func (k IndexKey) IsNone() bool {
+	return k.kind == noKey
+}
+
+// IsAny returns true if the index key is the [*] wildcard
+func (k IndexKey) IsAny() bool {
+	return k.kind == anyKey
+}
+
+// Matches returns true if the key is equal to other or is the [*] wildcard
+func (k IndexKey) Matches(other IndexKey) bool {
+	return k.IsAny() || k == other
+}
+
+// IsInt returns true if the index key is a count index
+func (k IndexKey) IsInt() bool {
+	return k.kind == intKey
+}
+
+// IsString returns true if the index key is a for_each key
+func (k IndexKey) IsString() bool {
+	return k.kind == stringKey
+}
+
+// AsInt returns the numeric value of a count index
+func (k IndexKey) AsInt() int {
+	return k.number
+}
+
+// AsString returns the value of a for_each key
+func (k IndexKey) AsString() string {
+	return k.text
+}
+
+// String renders the index key as it appears in a terraform address: [0] or ["a"].
+// An empty string is returned when there is no index key.
+func (k IndexKey) String() string {
+	switch k.kind {
+	case intKey:
+		return fmt.Sprintf("[%d]", k.number)
+	case stringKey:
+		return fmt.Sprintf("[%s]", quoteString(k.text))
+	case anyKey:
+		return "[*]"
+	default:
+		return ""
+	}
+}
+
+// UnmarshalJSON decodes index_key which is either a number, a string or null
+func (k *IndexKey) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*k = NoKey
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '"' {
+		var value string
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		*k = StringKey(value)
+		return nil
+	}
+
+	var value int
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("index_key must be an integer or a string: %w", err)
+	}
+	*k = IntKey(value)
+	return nil
+}
+
+// MarshalJSON encodes index_key as a number, a string or null
+func (k IndexKey) MarshalJSON() ([]byte, error) {
+	switch k.kind {
+	case intKey:
+		return json.Marshal(k.number)
+	case stringKey:
+		return json.Marshal(k.text)
+	case anyKey:
+		return nil, errors.New("wildcard index key can not be encoded")
+	default:
+		return []byte("null"), nil
+	}
+}
@@ -0,0 +1,80 @@
+/*
+MIT License
+
+# Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexKey_UnmarshalJSON(t *testing.T) {
+	t.Run("Numeric index key should be decoded as a count index", func(t *testing.T) {
+		var key state.IndexKey
+		assert.Nil(t, json.Unmarshal([]byte("3"), &key))
+		assert.Equal(t, state.IntKey(3), key)
+		assert.True(t, key.IsInt())
+		assert.Equal(t, 3, key.AsInt())
+	})
+
+	t.Run("String index key should be decoded as a for_each key", func(t *testing.T) {
+		var key state.IndexKey
+		assert.Nil(t, json.Unmarshal([]byte("\"eu-west-1\""), &key))
+		assert.Equal(t, state.StringKey("eu-west-1"), key)
+		assert.True(t, key.IsString())
+		assert.Equal(t, "eu-west-1", key.AsString())
+	})
+
+	t.Run("Null index key should be decoded as no key", func(t *testing.T) {
+		key := state.IntKey(1)
+		assert.Nil(t, json.Unmarshal([]byte("null"), &key))
+		assert.True(t, key.IsNone())
+	})
+
+	t.Run("Other types should returns an error", func(t *testing.T) {
+		var key state.IndexKey
+		assert.NotNil(t, json.Unmarshal([]byte("1.5"), &key))
+		assert.NotNil(t, json.Unmarshal([]byte("true"), &key))
+		assert.NotNil(t, json.Unmarshal([]byte("[]"), &key))
+	})
+}
+
+func TestIndexKey_MarshalJSON(t *testing.T) {
+	for _, key := range []state.IndexKey{state.NoKey, state.IntKey(0), state.StringKey("a")} {
+		data, err := json.Marshal(key)
+		assert.Nil(t, err)
+
+		var decoded state.IndexKey
+		assert.Nil(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, key, decoded)
+	}
+}
+
+func TestIndexKey_String(t *testing.T) {
+	assert.Equal(t, "", state.NoKey.String())
+	assert.Equal(t, "[0]", state.IntKey(0).String())
+	assert.Equal(t, "[\"a\"]", state.StringKey("a").String())
+}
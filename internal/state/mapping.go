@@ -0,0 +1,233 @@
+// Package state list all related resource to terraform state file
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Matcher selects resources of a terraform state
+type Matcher interface {
+	Matches(resource TerraformResource) bool
+}
+
+// InstanceMatcher selects resource instances of a terraform state. When a Matcher also
+// implements InstanceMatcher, ListResources only keeps the matching instances.
+type InstanceMatcher interface {
+	MatchesInstance(resource TerraformResource, instance TerraformResourceValue) bool
+}
+
+// allMatcher selects resources and instances matched by every matcher
+type allMatcher []Matcher
+
+// MatchAll returns a matcher selecting resources (and instances) selected by all matchers
+func MatchAll(matchers ...Matcher) Matcher {
+	return allMatcher(matchers)
+}
+
+func (m allMatcher) Matches(resource TerraformResource) bool {
+	for _, matcher := range m {
+		if !matcher.Matches(resource) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m allMatcher) MatchesInstance(resource TerraformResource, instance TerraformResourceValue) bool {
+	for _, matcher := range m {
+		if !matchesInstance(matcher, resource, instance) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesInstance returns true if matcher selects the instance. Matchers that only work at
+// resource level select every instance.
+func matchesInstance(matcher Matcher, resource TerraformResource, instance TerraformResourceValue) bool {
+	if instanceMatcher, ok := matcher.(InstanceMatcher); ok {
+		return instanceMatcher.MatchesInstance(resource, instance)
+	}
+	return true
+}
+
+// Mapping selects resources of a terraform state and gives their new location
+type Mapping interface {
+	Matcher
+	Target(resource TerraformResource) (Address, error)
+}
+
+// ModuleMapping is a Mapping able to move whole modules. ModuleTarget returns the module
+// instance containing resource which is moved, and its new location. ok is false when the
+// resource is not moved along with its module.
+type ModuleMapping interface {
+	Mapping
+	ModuleTarget(resource TerraformResource) (from ModulePath, to ModulePath, ok bool, err error)
+}
+
+// restrictedMapping only selects the resources of a mapping also matched by a filter
+type restrictedMapping struct {
+	Mapping
+	filter Matcher
+}
+
+// RestrictMapping returns a mapping selecting the resources matched by both mapping and filter
+func RestrictMapping(mapping Mapping, filter Matcher) Mapping {
+	return restrictedMapping{Mapping: mapping, filter: filter}
+}
+
+func (m restrictedMapping) Matches(resource TerraformResource) bool {
+	return m.Mapping.Matches(resource) && m.filter.Matches(resource)
+}
+
+func (m restrictedMapping) ModuleTarget(resource TerraformResource) (ModulePath, ModulePath, bool, error) {
+	if moduleMapping, ok := m.Mapping.(ModuleMapping); ok {
+		return moduleMapping.ModuleTarget(resource)
+	}
+	return nil, nil, false, nil
+}
+
+func (m restrictedMapping) MatchesInstance(resource TerraformResource, instance TerraformResourceValue) bool {
+	return matchesInstance(m.Mapping, resource, instance) && matchesInstance(m.filter, resource, instance)
+}
+
+// inPlaceMapping keeps the resources selected by a matcher at their current location
+type inPlaceMapping struct {
+	Matcher
+}
+
+// InPlaceMapping returns a mapping keeping the resources matched by matcher at their current
+// location, for instance when only their instance keys are converted
+func InPlaceMapping(matcher Matcher) Mapping {
+	return inPlaceMapping{Matcher: matcher}
+}
+
+func (m inPlaceMapping) Target(resource TerraformResource) (Address, error) {
+	return resource.Address(NoKey), nil
+}
+
+func (m inPlaceMapping) MatchesInstance(resource TerraformResource, instance TerraformResourceValue) bool {
+	return matchesInstance(m.Matcher, resource, instance)
+}
+
+// LocationMapping moves the resources selected by a filter to a single new location
+type LocationMapping struct {
+	Filter      ResourceFilter
+	NewLocation string
+}
+
+// NewLocationMapping creates a LocationMapping from the old_location and new_location
+// given on the command line. Both locations are validated.
+func NewLocationMapping(oldLocation string, newLocation string) (*LocationMapping, error) {
+	filter, err := CreateResourceFilterFromString(oldLocation)
+	if err != nil {
+		return nil, err
+	}
+	if err := filter.ValidateTarget(newLocation); err != nil {
+		return nil, err
+	}
+	return &LocationMapping{Filter: *filter, NewLocation: newLocation}, nil
+}
+
+// Matches returns true if the resource is selected by the mapping filter
+func (m LocationMapping) Matches(resource TerraformResource) bool {
+	return m.Filter.Matches(resource)
+}
+
+// Target returns the new location of resource
+func (m LocationMapping) Target(resource TerraformResource) (Address, error) {
+	return m.Filter.Target(resource, m.NewLocation)
+}
+
+// ModuleTarget returns the module instance moved along with resource when old location is a
+// module, and its new location
+func (m LocationMapping) ModuleTarget(resource TerraformResource) (ModulePath, ModulePath, bool, error) {
+	if !m.Filter.ModuleSubtree {
+		return nil, nil, false, nil
+	}
+
+	pattern, err := ParseModulePath(m.Filter.Module)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	module := resource.ModulePath()
+	length := module.PrefixLength(pattern)
+	if length < 0 {
+		return nil, nil, false, fmt.Errorf("resource %s does not match filter module %s", resource, m.Filter.Module)
+	}
+
+	expanded, err := m.Filter.ExpandTarget(resource, m.NewLocation)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	target, err := ParseModuleAddress(expanded)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return module[:length], target, true, nil
+}
+
+// RegexMapping moves the resources whose address matches a regular expression. The
+// target is a template where $1 or ${name} are replaced by the capture groups.
+type RegexMapping struct {
+	Pattern        *regexp.Regexp
+	TargetTemplate string
+}
+
+// NewRegexMapping compiles pattern which must match a whole resource address
+func NewRegexMapping(pattern string, targetTemplate string) (*RegexMapping, error) {
+	expression, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", pattern))
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression %q: %w", pattern, err)
+	}
+	return &RegexMapping{Pattern: expression, TargetTemplate: targetTemplate}, nil
+}
+
+// Matches returns true if the address of the resource matches the regular expression
+func (m RegexMapping) Matches(resource TerraformResource) bool {
+	return m.Pattern.MatchString(resource.String())
+}
+
+// Target returns the new location of resource by expanding the target template with the
+// capture groups of the regular expression.
+func (m RegexMapping) Target(resource TerraformResource) (Address, error) {
+	source := resource.String()
+	submatches := m.Pattern.FindStringSubmatchIndex(source)
+	if submatches == nil {
+		return Address{}, fmt.Errorf("resource %s does not match %s", source, m.Pattern)
+	}
+
+	expanded := string(m.Pattern.ExpandString(nil, m.TargetTemplate, source, submatches))
+	target, err := ParseAddress(expanded)
+	if err != nil {
+		return Address{}, fmt.Errorf("target of %s: %w", source, err)
+	}
+	if !target.Key.IsNone() {
+		return Address{}, fmt.Errorf("target of %s: instance keys are taken from the resource instances", source)
+	}
+	return target, nil
+}
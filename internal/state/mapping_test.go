@@ -0,0 +1,122 @@
+/*
+MIT License
+
+# Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state_test
+
+import (
+	"testing"
+
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func queuesState() state.TerraformState {
+	return state.TerraformState{
+		Version: 4,
+		Resources: []state.TerraformResource{
+			{Module: "module.old_orders", Mode: state.ManagedMode, Type: "aws_sqs_queue", Name: "main", Instances: []state.TerraformResourceValue{{}}},
+			{Module: "module.old_billing", Mode: state.ManagedMode, Type: "aws_sqs_queue", Name: "dlq", Instances: []state.TerraformResourceValue{{IndexKey: state.IntKey(0)}}},
+			{Module: "module.old_billing", Mode: state.ManagedMode, Type: "aws_sns_topic", Name: "main", Instances: []state.TerraformResourceValue{{}}},
+		},
+	}
+}
+
+func TestRegexMapping(t *testing.T) {
+	t.Run("Capture groups should be expanded in target", func(t *testing.T) {
+		mapping, err := state.NewRegexMapping(`module\.old_(\w+)\.aws_sqs_queue\.(\w+)`, `module.queues["$1"].aws_sqs_queue.$2`)
+		assert.Nil(t, err)
+
+		moves, err := queuesState().Moves(mapping)
+		assert.Nil(t, err)
+		assert.Len(t, moves, 2)
+		assert.Equal(t, "module.old_orders.aws_sqs_queue.main", moves[0].From.String())
+		assert.Equal(t, `module.queues["orders"].aws_sqs_queue.main`, moves[0].To.String())
+		assert.Equal(t, "module.old_billing.aws_sqs_queue.dlq[0]", moves[1].From.String())
+		assert.Equal(t, `module.queues["billing"].aws_sqs_queue.dlq[0]`, moves[1].To.String())
+	})
+
+	t.Run("Pattern should match the whole address", func(t *testing.T) {
+		mapping, err := state.NewRegexMapping(`aws_sqs_queue\.main`, `aws_sqs_queue.main`)
+		assert.Nil(t, err)
+		assert.False(t, mapping.Matches(queuesState().Resources[0]))
+	})
+
+	t.Run("Named capture groups should be expanded in target", func(t *testing.T) {
+		mapping, err := state.NewRegexMapping(`module\.old_(?P<team>\w+)\.aws_sns_topic\.main`, `module.topics.aws_sns_topic.${team}`)
+		assert.Nil(t, err)
+
+		moves, err := queuesState().Moves(mapping)
+		assert.Nil(t, err)
+		assert.Len(t, moves, 1)
+		assert.Equal(t, "module.topics.aws_sns_topic.billing", moves[0].To.String())
+	})
+
+	t.Run("Invalid regular expression should returns an error", func(t *testing.T) {
+		_, err := state.NewRegexMapping(`module\.(`, `aws_sqs_queue.main`)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Invalid expanded target should returns an error", func(t *testing.T) {
+		mapping, err := state.NewRegexMapping(`module\.old_(\w+)\.aws_sqs_queue\.(\w+)`, `module.$1`)
+		assert.Nil(t, err)
+
+		_, err = queuesState().Moves(mapping)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestLocationMapping(t *testing.T) {
+	t.Run("Resources should be moved to the new location", func(t *testing.T) {
+		mapping, err := state.NewLocationMapping("module.old_billing", "module.billing")
+		assert.Nil(t, err)
+
+		moves, err := queuesState().Moves(mapping)
+		assert.Nil(t, err)
+		assert.Len(t, moves, 2)
+		assert.Equal(t, "module.billing.aws_sqs_queue.dlq[0]", moves[0].To.String())
+		assert.Equal(t, "module.billing.aws_sns_topic.main", moves[1].To.String())
+	})
+
+	t.Run("Invalid locations should returns an error", func(t *testing.T) {
+		_, err := state.NewLocationMapping("module.", "module.billing")
+		assert.NotNil(t, err)
+
+		_, err = state.NewLocationMapping("module.old_billing", "aws_sqs_queue.dlq")
+		assert.NotNil(t, err)
+	})
+}
+
+func TestInPlaceMapping(t *testing.T) {
+	t.Run("Resources should keep their location", func(t *testing.T) {
+		filter, err := state.CreateResourceFilterFromString("module.old_orders.aws_sqs_queue.*")
+		assert.Nil(t, err)
+		conversion, err := state.NewCountConversion("")
+		assert.Nil(t, err)
+
+		moves, err := queuesState().ConvertedMoves(state.InPlaceMapping(filter), conversion)
+		assert.Nil(t, err)
+		assert.Len(t, moves, 1)
+		assert.Equal(t, "module.old_orders.aws_sqs_queue.main", moves[0].From.String())
+		assert.Equal(t, "module.old_orders.aws_sqs_queue.main[0]", moves[0].To.String())
+	})
+}
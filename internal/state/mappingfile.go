@@ -0,0 +1,308 @@
+// Package state list all related resource to terraform state file
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Formats of mapping files
+const (
+	MappingFormatYAML = "yaml"
+	MappingFormatCSV  = "csv"
+)
+
+// MappingEntry is an entry of a mapping file. Resources are selected either with From, which
+// accepts the same syntax as filters, or with the regular expression Regex. To is the new
+// location, which may reference capture groups of Regex.
+type MappingEntry struct {
+	From  string `yaml:"from"`
+	Regex string `yaml:"regex"`
+	To    string `yaml:"to"`
+	// Line is the line of the entry in the mapping file
+	Line int `yaml:"-"`
+}
+
+// String renders the entry as source -> target
+func (e MappingEntry) String() string {
+	source := e.From
+	if e.Regex != "" {
+		source = fmt.Sprintf("/%s/", e.Regex)
+	}
+	return fmt.Sprintf("%s -> %s", source, e.To)
+}
+
+// Mapping creates the mapping described by the entry
+func (e MappingEntry) Mapping() (Mapping, error) {
+	switch {
+	case strings.TrimSpace(e.To) == "":
+		return nil, fmt.Errorf("line %d: to is required", e.Line)
+	case e.From != "" && e.Regex != "":
+		return nil, fmt.Errorf("line %d: from and regex are mutually exclusive", e.Line)
+	case e.Regex != "":
+		mapping, err := NewRegexMapping(e.Regex, e.To)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", e.Line, err)
+		}
+		return mapping, nil
+	case strings.TrimSpace(e.From) != "":
+		mapping, err := NewLocationMapping(e.From, e.To)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", e.Line, err)
+		}
+		return mapping, nil
+	default:
+		return nil, fmt.Errorf("line %d: from or regex is required", e.Line)
+	}
+}
+
+// MappingEntriesFromReader reads the entries of a mapping file. YAML files hold a list of
+// entries under the moves key:
+//
+//	moves:
+//	  - from: module.legacy.aws_s3_bucket.logs
+//	    to: module.storage.aws_s3_bucket.logs
+//	  - regex: 'module\.old_(\w+)\.aws_sqs_queue\.main'
+//	    to: 'module.queues["$1"].aws_sqs_queue.main'
+//
+// CSV files start with a header naming the from, regex and to columns. Lines starting with #
+// are ignored.
+func MappingEntriesFromReader(reader io.Reader, format string) ([]MappingEntry, error) {
+	switch format {
+	case MappingFormatYAML:
+		return yamlMappingEntries(reader)
+	case MappingFormatCSV:
+		return csvMappingEntries(reader)
+	default:
+		return nil, fmt.Errorf("unsupported mapping format %q: expected %s or %s", format, MappingFormatYAML, MappingFormatCSV)
+	}
+}
+
+// MappingEntriesFromFile reads the entries of a mapping file whose format is given by its
+// extension: .yaml, .yml or .csv
+func MappingEntriesFromFile(path string) ([]MappingEntry, error) {
+	var format string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		format = MappingFormatYAML
+	case ".csv":
+		format = MappingFormatCSV
+	default:
+		return nil, fmt.Errorf("%s: unsupported mapping file extension, expected .yaml, .yml or .csv", path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries, err := MappingEntriesFromReader(file, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return entries, nil
+}
+
+func yamlMappingEntries(reader io.Reader) ([]MappingEntry, error) {
+	var document struct {
+		Moves []yaml.Node `yaml:"moves"`
+	}
+	decoder := yaml.NewDecoder(reader)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&document); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	entries := make([]MappingEntry, 0, len(document.Moves))
+	for _, node := range document.Moves {
+		if node.Kind == yaml.MappingNode {
+			for index := 0; index < len(node.Content); index += 2 {
+				switch field := node.Content[index].Value; field {
+				case "from", "regex", "to":
+				default:
+					return nil, fmt.Errorf("line %d: unknown field %q: expected from, regex and to", node.Content[index].Line, field)
+				}
+			}
+		}
+
+		entry := MappingEntry{}
+		if err := node.Decode(&entry); err != nil {
+			return nil, fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		entry.Line = node.Line
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func csvMappingEntries(reader io.Reader) ([]MappingEntry, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.Comment = '#'
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+	// addresses such as module.app["eu"] hold quotes which are kept as is
+	csvReader.LazyQuotes = true
+
+	header, err := csvReader.Read()
+	if errors.Is(err, io.EOF) {
+		return []MappingEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{}
+	for index, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "from", "regex", "to":
+			columns[name] = index
+		default:
+			return nil, fmt.Errorf("unknown column %q: expected from, regex and to", name)
+		}
+	}
+	if _, found := columns["to"]; !found {
+		return nil, errors.New("header must name a to column")
+	}
+
+	entries := []MappingEntry{}
+	for {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := csvReader.FieldPos(0)
+		value := func(column string) string {
+			index, found := columns[column]
+			if !found || index >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[index])
+		}
+		entries = append(entries, MappingEntry{From: value("from"), Regex: value("regex"), To: value("to"), Line: line})
+	}
+}
+
+// MappingSet combines the entries of a mapping file. Each resource is expected to be selected
+// by a single entry, which Validate checks against a state.
+type MappingSet struct {
+	Entries  []MappingEntry
+	mappings []Mapping
+}
+
+// NewMappingSet creates a MappingSet, every entry being validated
+func NewMappingSet(entries []MappingEntry) (*MappingSet, error) {
+	if len(entries) == 0 {
+		return nil, errors.New("mapping file does not declare any move")
+	}
+
+	set := MappingSet{Entries: entries}
+	for _, entry := range entries {
+		mapping, err := entry.Mapping()
+		if err != nil {
+			return nil, err
+		}
+		set.mappings = append(set.mappings, mapping)
+	}
+	return &set, nil
+}
+
+func (m MappingSet) mappingFor(resource TerraformResource) (Mapping, bool) {
+	for _, mapping := range m.mappings {
+		if mapping.Matches(resource) {
+			return mapping, true
+		}
+	}
+	return nil, false
+}
+
+// Matches returns true if an entry selects the resource
+func (m MappingSet) Matches(resource TerraformResource) bool {
+	_, found := m.mappingFor(resource)
+	return found
+}
+
+// Target returns the new location of resource given by the entry selecting it
+func (m MappingSet) Target(resource TerraformResource) (Address, error) {
+	mapping, found := m.mappingFor(resource)
+	if !found {
+		return Address{}, fmt.Errorf("resource %s is not selected by the mapping file", resource)
+	}
+	return mapping.Target(resource)
+}
+
+// ModuleTarget returns the module moved along with resource when the entry selecting it
+// moves a whole module
+func (m MappingSet) ModuleTarget(resource TerraformResource) (ModulePath, ModulePath, bool, error) {
+	mapping, found := m.mappingFor(resource)
+	if !found {
+		return nil, nil, false, nil
+	}
+	if moduleMapping, ok := mapping.(ModuleMapping); ok {
+		return moduleMapping.ModuleTarget(resource)
+	}
+	return nil, nil, false, nil
+}
+
+// Validate checks the mapping set against a state: every entry must select at least one
+// resource and no resource may be selected by several entries. All problems are reported.
+func (m MappingSet) Validate(s TerraformState) error {
+	problems := []string{}
+	for index, mapping := range m.mappings {
+		if len(s.ListResources(mapping)) == 0 {
+			problems = append(problems, fmt.Sprintf("line %d: %s does not match any resource", m.Entries[index].Line, m.Entries[index]))
+		}
+	}
+
+	for _, resource := range s.Resources {
+		lines := []string{}
+		for index, mapping := range m.mappings {
+			if mapping.Matches(resource) {
+				lines = append(lines, fmt.Sprint(m.Entries[index].Line))
+			}
+		}
+		if len(lines) > 1 {
+			problems = append(problems, fmt.Sprintf("resource %s is selected by the entries at lines %s", resource, strings.Join(lines, ", ")))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid mapping file:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
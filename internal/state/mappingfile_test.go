@@ -0,0 +1,122 @@
+/*
+MIT License
+
+# Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/stretchr/testify/assert"
+)
+
+const yamlMapping = `moves:
+  - from: module.old_orders.aws_sqs_queue.main
+    to: module.orders.aws_sqs_queue.main
+  - regex: 'module\.old_billing\.(\w+)\.(\w+)'
+    to: 'module.billing.$1.$2'
+`
+
+func TestMappingEntriesFromReader(t *testing.T) {
+	t.Run("YAML entries should be read with their line", func(t *testing.T) {
+		entries, err := state.MappingEntriesFromReader(strings.NewReader(yamlMapping), state.MappingFormatYAML)
+		assert.Nil(t, err)
+		assert.Equal(t, []state.MappingEntry{
+			{From: "module.old_orders.aws_sqs_queue.main", To: "module.orders.aws_sqs_queue.main", Line: 2},
+			{Regex: `module\.old_billing\.(\w+)\.(\w+)`, To: "module.billing.$1.$2", Line: 4},
+		}, entries)
+	})
+
+	t.Run("CSV entries should be read with their line", func(t *testing.T) {
+		csv := "from,to\n# comment\nmodule.app[\"eu\"].aws_s3_bucket.logs, module.storage.aws_s3_bucket.logs\n"
+		entries, err := state.MappingEntriesFromReader(strings.NewReader(csv), state.MappingFormatCSV)
+		assert.Nil(t, err)
+		assert.Equal(t, []state.MappingEntry{
+			{From: `module.app["eu"].aws_s3_bucket.logs`, To: "module.storage.aws_s3_bucket.logs", Line: 3},
+		}, entries)
+	})
+
+	t.Run("Unknown YAML field should returns an error", func(t *testing.T) {
+		_, err := state.MappingEntriesFromReader(strings.NewReader("moves:\n  - source: a.b\n    to: a.c\n"), state.MappingFormatYAML)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Unknown CSV column should returns an error", func(t *testing.T) {
+		_, err := state.MappingEntriesFromReader(strings.NewReader("source,to\na.b,a.c\n"), state.MappingFormatCSV)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Unknown format should returns an error", func(t *testing.T) {
+		_, err := state.MappingEntriesFromReader(strings.NewReader(""), "json")
+		assert.NotNil(t, err)
+	})
+}
+
+func TestMappingSet(t *testing.T) {
+	t.Run("Each entry should move its resources", func(t *testing.T) {
+		entries, err := state.MappingEntriesFromReader(strings.NewReader(yamlMapping), state.MappingFormatYAML)
+		assert.Nil(t, err)
+		set, err := state.NewMappingSet(entries)
+		assert.Nil(t, err)
+		assert.Nil(t, set.Validate(queuesState()))
+
+		moves, err := queuesState().Moves(set)
+		assert.Nil(t, err)
+		assert.Len(t, moves, 3)
+		assert.Equal(t, "module.orders.aws_sqs_queue.main", moves[0].To.String())
+		assert.Equal(t, "module.billing.aws_sqs_queue.dlq[0]", moves[1].To.String())
+		assert.Equal(t, "module.billing.aws_sns_topic.main", moves[2].To.String())
+	})
+
+	t.Run("Unmatched entries should be reported", func(t *testing.T) {
+		set, err := state.NewMappingSet([]state.MappingEntry{
+			{From: "aws_lb.main", To: "aws_lb.public", Line: 1},
+			{From: "aws_lb.private", To: "aws_lb.internal", Line: 2},
+		})
+		assert.Nil(t, err)
+
+		err = set.Validate(queuesState())
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "line 1")
+		assert.Contains(t, err.Error(), "line 2")
+	})
+
+	t.Run("Resources selected by several entries should be reported", func(t *testing.T) {
+		set, err := state.NewMappingSet([]state.MappingEntry{
+			{From: "module.old_orders", To: "module.orders", Line: 1},
+			{From: "module.*.aws_sqs_queue.main", To: "aws_sqs_queue.main", Line: 2},
+		})
+		assert.Nil(t, err)
+		assert.NotNil(t, set.Validate(queuesState()))
+	})
+
+	t.Run("Invalid entries should returns an error", func(t *testing.T) {
+		_, err := state.NewMappingSet([]state.MappingEntry{{From: "aws_lb.main"}})
+		assert.NotNil(t, err)
+		_, err = state.NewMappingSet([]state.MappingEntry{{From: "aws_lb.main", Regex: "aws_lb", To: "aws_lb.public"}})
+		assert.NotNil(t, err)
+		_, err = state.NewMappingSet(nil)
+		assert.NotNil(t, err)
+	})
+}
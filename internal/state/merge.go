@@ -0,0 +1,228 @@
+// Package state list all related resource to terraform state file
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MergeSource is a state merged with others. Its resources are moved to the module Into,
+// which is the root module when empty. Into must be a module instance address, without globs
+// nor wildcard keys.
+type MergeSource struct {
+	Name  string
+	State *TerraformState
+	Into  ModulePath
+}
+
+// Merge combines the resources of several states into a new state with a fresh lineage,
+// serial 1 and the most recent terraform version of the sources. An error is returned when
+// a managed resource is found in several sources, or when several managed instances of the
+// same type have the same id. Data sources found in several sources, which terraform reads
+// again on every plan, are taken from the first source. Outputs of sources moved to a module, and outputs found with different
+// values in several sources, are not merged and reported as warnings. Sources without lineage,
+// read from terraform show output, are refused with ErrNoLineage: they miss the provider aliases
+// and private data of their resources.
+func Merge(sources []MergeSource) (*TerraformState, []string, error) {
+	lineage, err := NewLineage()
+	if err != nil {
+		return nil, nil, err
+	}
+	merged := TerraformState{
+		Version:   StateFormatVersion,
+		Serial:    1,
+		Lineage:   lineage,
+		Outputs:   map[string]TerraformOutputValue{},
+		Resources: []TerraformResource{},
+	}
+
+	warnings := []string{}
+	problems := []string{}
+	resources := map[string]string{}
+	ids := map[string]string{}
+	outputs := map[string]string{}
+	for _, source := range sources {
+		if source.State.Lineage == "" {
+			return nil, nil, fmt.Errorf("%w: %s", ErrNoLineage, source.Name)
+		}
+		if !source.Into.isInstance() {
+			return nil, nil, fmt.Errorf("%s: %s is not a module instance address", source.Name, source.Into)
+		}
+		if compareVersions(source.State.TerraformVersion, merged.TerraformVersion) > 0 {
+			merged.TerraformVersion = source.State.TerraformVersion
+		}
+
+		for _, resource := range source.State.Resources {
+			resource, err := resourceInto(resource, source.Into)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", source.Name, err)
+			}
+
+			address := resource.String()
+			if previous, found := resources[address]; found {
+				if resource.Mode == DataMode {
+					continue
+				}
+				problems = append(problems, fmt.Sprintf("%s is found in both %s and %s", address, previous, source.Name))
+				continue
+			}
+			resources[address] = source.Name
+
+			for _, instance := range resource.Instances {
+				id, found := instance.Attributes["id"]
+				if resource.Mode != ManagedMode || instance.Deposed != "" || !found || id == nil || fmt.Sprint(id) == "" {
+					continue
+				}
+				key := fmt.Sprintf("%s %v", resource.Type, id)
+				current := fmt.Sprintf("%s (%s)", resource.Address(instance.IndexKey), source.Name)
+				if previous, found := ids[key]; found {
+					problems = append(problems, fmt.Sprintf("%s id %v is used by both %s and %s", resource.Type, id, previous, current))
+					continue
+				}
+				ids[key] = current
+			}
+			merged.Resources = append(merged.Resources, resource)
+		}
+
+		for _, name := range sortedOutputNames(source.State.Outputs) {
+			output := source.State.Outputs[name]
+			if !source.Into.IsRoot() {
+				warnings = append(warnings, fmt.Sprintf("output %s of %s is not merged, outputs of modules are not stored in states", name, source.Name))
+				continue
+			}
+			if previous, found := outputs[name]; found {
+				if !reflect.DeepEqual(merged.Outputs[name], output) {
+					warnings = append(warnings, fmt.Sprintf("output %s has different values in %s and %s, the value of %s is kept", name, previous, source.Name, previous))
+				}
+				continue
+			}
+			outputs[name] = source.Name
+			merged.Outputs[name] = output
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, nil, fmt.Errorf("states can not be merged:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return &merged, warnings, nil
+}
+
+// resourceInto returns a copy of resource moved to the module into, including the
+// dependencies recorded for its instances and its provider configuration when declared
+// in a module. Provider configurations of the root module are kept, as they are
+// inherited by modules.
+func resourceInto(resource TerraformResource, into ModulePath) (TerraformResource, error) {
+	if into.IsRoot() {
+		return resource, nil
+	}
+
+	resource.Module = append(append(ModulePath{}, into...), resource.ModulePath()...).String()
+	if resource.Provider != "" {
+		provider, err := ParseProviderAddress(resource.Provider)
+		if err != nil {
+			return resource, fmt.Errorf("invalid provider of %s: %w", resource, err)
+		}
+		if !provider.Module.IsRoot() {
+			resource.Provider = configModule(into).String() + "." + resource.Provider
+		}
+	}
+	instances := make([]TerraformResourceValue, 0, len(resource.Instances))
+	for _, instance := range resource.Instances {
+		if data, found := instance.Extra["dependencies"]; found {
+			dependencies := []string{}
+			if err := json.Unmarshal(data, &dependencies); err != nil {
+				return resource, fmt.Errorf("invalid dependencies of %s: %w", resource.Address(instance.IndexKey), err)
+			}
+			for index, dependency := range dependencies {
+				dependencies[index] = configModule(into).String() + "." + dependency
+			}
+			encoded, err := json.Marshal(dependencies)
+			if err != nil {
+				return resource, err
+			}
+
+			extra := make(map[string]json.RawMessage, len(instance.Extra))
+			for name, value := range instance.Extra {
+				extra[name] = value
+			}
+			extra["dependencies"] = encoded
+			instance.Extra = extra
+		}
+		instances = append(instances, instance)
+	}
+	resource.Instances = instances
+	return resource, nil
+}
+
+// sortedOutputNames returns the names of outputs in lexical order
+func sortedOutputNames(outputs map[string]TerraformOutputValue) []string {
+	names := make([]string, 0, len(outputs))
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// compareVersions compares two terraform versions such as 1.5.7, returning a negative number
+// when a is older than b, 0 when they are equal and a positive number otherwise. Pre-release
+// suffixes are ignored and an empty version is older than any other.
+func compareVersions(a string, b string) int {
+	partsA, partsB := versionNumbers(a), versionNumbers(b)
+	for index := 0; index < len(partsA) || index < len(partsB); index++ {
+		var numberA, numberB int
+		if index < len(partsA) {
+			numberA = partsA[index]
+		}
+		if index < len(partsB) {
+			numberB = partsB[index]
+		}
+		if numberA != numberB {
+			return numberA - numberB
+		}
+	}
+	return 0
+}
+
+func versionNumbers(version string) []int {
+	version, _, _ = strings.Cut(strings.TrimPrefix(version, "v"), "-")
+	if version == "" {
+		return nil
+	}
+	numbers := []int{}
+	for _, part := range strings.Split(version, ".") {
+		number, err := strconv.Atoi(part)
+		if err != nil {
+			break
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers
+}
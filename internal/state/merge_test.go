@@ -0,0 +1,176 @@
+/*
+MIT License
+
+# Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func mergedState(version string, resources ...state.TerraformResource) *state.TerraformState {
+	return &state.TerraformState{
+		Version:          4,
+		TerraformVersion: version,
+		Serial:           7,
+		Lineage:          "source",
+		Outputs:          map[string]state.TerraformOutputValue{},
+		Resources:        resources,
+	}
+}
+
+func mergedResource(module string, name string, id string) state.TerraformResource {
+	return state.TerraformResource{
+		Module:    module,
+		Mode:      state.ManagedMode,
+		Type:      "aws_instance",
+		Name:      name,
+		Instances: []state.TerraformResourceValue{{Attributes: map[string]interface{}{"id": id}}},
+	}
+}
+
+func TestMerge(t *testing.T) {
+	t.Run("Merge should combine resources in a new state", func(t *testing.T) {
+		a := mergedState("1.5.7", mergedResource("", "api", "i-1"))
+		b := mergedState("1.10.0", mergedResource("", "web", "i-2"))
+
+		merged, warnings, err := state.Merge([]state.MergeSource{{Name: "a", State: a}, {Name: "b", State: b}})
+		assert.Nil(t, err)
+		assert.Empty(t, warnings)
+		assert.Len(t, merged.Resources, 2)
+		assert.Equal(t, "1.10.0", merged.TerraformVersion)
+		assert.Equal(t, 1, merged.Serial)
+		assert.NotEqual(t, "source", merged.Lineage)
+	})
+
+	t.Run("Merge should move resources and their dependencies to modules", func(t *testing.T) {
+		resource := mergedResource("module.db", "api", "i-1")
+		resource.Instances[0].Extra = map[string]json.RawMessage{"dependencies": json.RawMessage(`["module.db.aws_vpc.main"]`)}
+		a := mergedState("1.5.7", resource)
+		b := mergedState("1.5.7", mergedResource("", "api", "i-2"))
+		into, err := state.ParseModulePath(`module.a["x"]`)
+		assert.Nil(t, err)
+
+		merged, _, err := state.Merge([]state.MergeSource{{Name: "a", State: a, Into: into}, {Name: "b", State: b}})
+		assert.Nil(t, err)
+		assert.Equal(t, `module.a["x"].module.db.aws_instance.api`, merged.Resources[0].String())
+		assert.Equal(t, json.RawMessage(`["module.a.module.db.aws_vpc.main"]`), merged.Resources[0].Instances[0].Extra["dependencies"])
+		assert.Equal(t, json.RawMessage(`["module.db.aws_vpc.main"]`), resource.Instances[0].Extra["dependencies"])
+		assert.Equal(t, "aws_instance.api", merged.Resources[1].String())
+	})
+
+	t.Run("Merge should move provider configurations declared in modules", func(t *testing.T) {
+		scoped := mergedResource("module.db", "api", "i-1")
+		scoped.Provider = `module.db.provider["registry.terraform.io/hashicorp/aws"].eu_west`
+		inherited := mergedResource("module.db", "web", "i-2")
+		inherited.Provider = `provider["registry.terraform.io/hashicorp/aws"]`
+		a := mergedState("1.5.7", scoped, inherited)
+		into, err := state.ParseModulePath(`module.a["x"]`)
+		assert.Nil(t, err)
+
+		merged, _, err := state.Merge([]state.MergeSource{{Name: "a", State: a, Into: into}})
+		assert.Nil(t, err)
+		assert.Equal(t, `module.a.module.db.provider["registry.terraform.io/hashicorp/aws"].eu_west`, merged.Resources[0].Provider)
+		assert.Equal(t, `provider["registry.terraform.io/hashicorp/aws"]`, merged.Resources[1].Provider)
+	})
+
+	t.Run("Merge should returns an error when a resource is found in several states", func(t *testing.T) {
+		a := mergedState("1.5.7", mergedResource("", "api", "i-1"))
+		b := mergedState("1.5.7", mergedResource("", "api", "i-2"))
+
+		_, _, err := state.Merge([]state.MergeSource{{Name: "a", State: a}, {Name: "b", State: b}})
+		assert.EqualError(t, err, "states can not be merged:\n  aws_instance.api is found in both a and b")
+	})
+
+	t.Run("Merge should keep the first occurrence of data sources found in several states", func(t *testing.T) {
+		region := func(name string) state.TerraformResource {
+			return state.TerraformResource{
+				Mode:      state.DataMode,
+				Type:      "aws_region",
+				Name:      "current",
+				Instances: []state.TerraformResourceValue{{Attributes: map[string]interface{}{"id": name}}},
+			}
+		}
+		a := mergedState("1.5.7", mergedResource("", "api", "i-1"), region("eu-west-1"))
+		b := mergedState("1.5.7", mergedResource("", "web", "i-2"), region("eu-west-3"))
+
+		merged, warnings, err := state.Merge([]state.MergeSource{{Name: "a", State: a}, {Name: "b", State: b}})
+		assert.Nil(t, err)
+		assert.Empty(t, warnings)
+		assert.Len(t, merged.Resources, 3)
+		assert.Equal(t, "eu-west-1", merged.Resources[1].Instances[0].Attributes["id"])
+	})
+
+	t.Run("Merge should returns an error when an id is found in several states", func(t *testing.T) {
+		a := mergedState("1.5.7", mergedResource("", "api", "i-1"))
+		b := mergedState("1.5.7", mergedResource("", "web", "i-1"))
+
+		_, _, err := state.Merge([]state.MergeSource{{Name: "a", State: a}, {Name: "b", State: b}})
+		assert.EqualError(t, err, "states can not be merged:\n  aws_instance id i-1 is used by both aws_instance.api (a) and aws_instance.web (b)")
+	})
+
+	t.Run("Merge should returns an error for a state read from terraform show output", func(t *testing.T) {
+		a := mergedState("1.5.7", mergedResource("", "api", "i-1"))
+		b := mergedState("1.5.7", mergedResource("", "web", "i-2"))
+		b.Lineage = ""
+
+		_, _, err := state.Merge([]state.MergeSource{{Name: "a", State: a}, {Name: "show.json", State: b}})
+		assert.ErrorIs(t, err, state.ErrNoLineage)
+	})
+
+	t.Run("Merge should returns an error when resources are moved to a module pattern", func(t *testing.T) {
+		a := mergedState("1.5.7", mergedResource("", "api", "i-1"))
+		for _, value := range []string{"module.*", "module.**", "module.app[*]"} {
+			into, err := state.ParseModulePath(value)
+			assert.Nil(t, err)
+
+			_, _, err = state.Merge([]state.MergeSource{{Name: "a", State: a, Into: into}})
+			assert.EqualError(t, err, "a: "+value+" is not a module instance address")
+		}
+	})
+
+	t.Run("Merge should report clashing outputs", func(t *testing.T) {
+		a := mergedState("1.5.7")
+		a.Outputs["region"] = state.TerraformOutputValue{Type: state.CtyString, Value: "eu-west-1"}
+		a.Outputs["env"] = state.TerraformOutputValue{Type: state.CtyString, Value: "prod"}
+		b := mergedState("1.5.7")
+		b.Outputs["region"] = state.TerraformOutputValue{Type: state.CtyString, Value: "us-east-1"}
+		b.Outputs["env"] = state.TerraformOutputValue{Type: state.CtyString, Value: "prod"}
+		c := mergedState("1.5.7")
+		c.Outputs["vpc_id"] = state.TerraformOutputValue{Type: state.CtyString, Value: "vpc-1"}
+		into, err := state.ParseModulePath("module.c")
+		assert.Nil(t, err)
+
+		merged, warnings, err := state.Merge([]state.MergeSource{{Name: "a", State: a}, {Name: "b", State: b}, {Name: "c", State: c, Into: into}})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{
+			"output region has different values in a and b, the value of a is kept",
+			"output vpc_id of c is not merged, outputs of modules are not stored in states",
+		}, warnings)
+		assert.Len(t, merged.Outputs, 2)
+		assert.Equal(t, "eu-west-1", merged.Outputs["region"].Value)
+	})
+}
@@ -0,0 +1,273 @@
+// Package state list all related resource to terraform state file
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ModuleInstance is one step of a module path such as module.app["eu"]
+type ModuleInstance struct {
+	Name string
+	Key  IndexKey
+}
+
+// ModulePath is the path of a module instance from the root module, for instance
+// module.network.module.subnets[0]. The root module has an empty path.
+type ModulePath []ModuleInstance
+
+func (m ModuleInstance) String() string {
+	return fmt.Sprintf("module.%s%s", m.Name, m.Key)
+}
+
+func (p ModulePath) String() string {
+	steps := make([]string, 0, len(p))
+	for _, step := range p {
+		steps = append(steps, step.String())
+	}
+	return strings.Join(steps, ".")
+}
+
+// IsRoot returns true for the path of the root module
+func (p ModulePath) IsRoot() bool {
+	return len(p) == 0
+}
+
+// Matches returns true if other matches the pattern p. Module names of p may contain
+// globs: * matches any sequence of characters and a name containing ** additionally
+// matches any number of nested modules (module.** matches any module path). Keys of p
+// must be equal to the keys of other unless they are the [*] wildcard. A module name with
+// globs and no key matches every instance of the modules it selects, so module.* matches
+// module.app["eu"] as well as module.network.
+func (p ModulePath) Matches(other ModulePath) bool {
+	if len(p) == 0 {
+		return len(other) == 0
+	}
+
+	step := p[0]
+	if step.Name == "**" {
+		for index := 0; index <= len(other); index++ {
+			if p[1:].Matches(other[index:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(other) == 0 {
+		return false
+	}
+	if strings.Contains(step.Name, "**") {
+		if !matchGlob(strings.ReplaceAll(step.Name, "**", "*"), other[0].Name) {
+			return false
+		}
+		return append(ModulePath{{Name: "**"}}, p[1:]...).Matches(other[1:])
+	}
+	if !matchGlob(step.Name, other[0].Name) || !step.matchesKey(other[0].Key) {
+		return false
+	}
+	return p[1:].Matches(other[1:])
+}
+
+// HasPrefix returns true if prefix matches the first steps of p
+func (p ModulePath) HasPrefix(prefix ModulePath) bool {
+	return p.PrefixLength(prefix) >= 0
+}
+
+// PrefixLength returns the number of steps of p matched by prefix, or -1 when prefix
+// does not match the beginning of p. The shortest match is returned.
+func (p ModulePath) PrefixLength(prefix ModulePath) int {
+	for length := 0; length <= len(p); length++ {
+		if prefix.Matches(p[:length]) {
+			return length
+		}
+	}
+	return -1
+}
+
+// matchesKey returns true if key is matched by the key of the step. A step whose name has
+// globs and no key matches any key.
+func (m ModuleInstance) matchesKey(key IndexKey) bool {
+	return m.Key.Matches(key) || (m.Key.IsNone() && strings.ContainsAny(m.Name, "*?"))
+}
+
+// isRecursive returns true if one of the module names of p contains **
+func (p ModulePath) isRecursive() bool {
+	for _, step := range p {
+		if strings.Contains(step.Name, "**") {
+			return true
+		}
+	}
+	return false
+}
+
+// isInstance returns true if p is the address of a module instance: names are identifiers,
+// without globs, and keys are not wildcards
+func (p ModulePath) isInstance() bool {
+	for _, step := range p {
+		if !isIdentifier(step.Name) || step.Key.IsAny() {
+			return false
+		}
+	}
+	return true
+}
+
+// matchGlob returns true if value matches pattern, * matching any sequence of characters
+// and ? any single character. An empty pattern matches everything.
+func matchGlob(pattern string, value string) bool {
+	if pattern == "" || pattern == value {
+		return true
+	}
+	matched, err := path.Match(pattern, value)
+	return err == nil && matched
+}
+
+// ParseModulePath parses a module path as found in state files (module.app["eu"].module.db)
+func ParseModulePath(input string) (ModulePath, error) {
+	if strings.TrimSpace(input) == "" {
+		return ModulePath{}, nil
+	}
+
+	steps, err := parseTraversal(input)
+	if err != nil {
+		return nil, err
+	}
+
+	path, steps, err := parseModuleSteps(steps)
+	if err != nil {
+		return nil, err
+	}
+	if len(steps) > 0 {
+		return nil, fmt.Errorf("invalid module path %q", input)
+	}
+	return path, nil
+}
+
+// parseModuleSteps consumes the leading module.name[key] steps of a traversal
+func parseModuleSteps(steps []traversalStep) (ModulePath, []traversalStep, error) {
+	path := ModulePath{}
+	for len(steps) > 0 && steps[0].name == "module" {
+		if !steps[0].key.IsNone() || len(steps) < 2 {
+			return nil, nil, errors.New("module must be followed by a module name")
+		}
+		path = append(path, ModuleInstance{Name: steps[1].name, Key: steps[1].key})
+		steps = steps[2:]
+	}
+	return path, steps, nil
+}
+
+// traversalStep is a name optionally followed by an index key: name, name[0], name["a"] or name[*]
+type traversalStep struct {
+	name string
+	key  IndexKey
+}
+
+func (s traversalStep) String() string {
+	return s.name + s.key.String()
+}
+
+// parseTraversal splits an address into its dot separated steps, taking care of dots
+// that may appear in quoted index keys.
+func parseTraversal(input string) ([]traversalStep, error) {
+	steps := []traversalStep{}
+	position := 0
+	for {
+		start := position
+		for position < len(input) && !strings.ContainsRune(".[]\" \t\n", rune(input[position])) {
+			position++
+		}
+		if position == start {
+			return nil, fmt.Errorf("missing name at position %d in %q", start, input)
+		}
+		step := traversalStep{name: input[start:position]}
+
+		if position < len(input) && input[position] == '[' {
+			key, next, err := parseIndexKey(input, position)
+			if err != nil {
+				return nil, err
+			}
+			step.key = key
+			position = next
+		}
+		steps = append(steps, step)
+
+		if position == len(input) {
+			return steps, nil
+		}
+		if input[position] != '.' {
+			return nil, fmt.Errorf("unexpected character %q at position %d in %q", input[position], position, input)
+		}
+		position++
+	}
+}
+
+// parseIndexKey parses the index key starting at the opening bracket found at position
+// and returns the position following the closing bracket.
+func parseIndexKey(input string, position int) (IndexKey, int, error) {
+	end := position + 1
+	if end < len(input) && input[end] == '"' {
+		end++
+		for end < len(input) && input[end] != '"' {
+			if input[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(input) {
+			return NoKey, 0, fmt.Errorf("unterminated string key in %q", input)
+		}
+		end++
+	} else {
+		for end < len(input) && input[end] != ']' {
+			end++
+		}
+	}
+
+	if end >= len(input) || input[end] != ']' {
+		return NoKey, 0, fmt.Errorf("missing closing bracket in %q", input)
+	}
+
+	raw := input[position+1 : end]
+	switch {
+	case raw == "*":
+		return AnyKey, end + 1, nil
+	case strings.HasPrefix(raw, "\""):
+		value, err := unquoteString(raw)
+		if err != nil {
+			return NoKey, 0, fmt.Errorf("invalid string key %s in %q", raw, input)
+		}
+		return StringKey(value), end + 1, nil
+	default:
+		value, err := strconv.Atoi(raw)
+		if err != nil || strings.TrimLeft(raw, "0123456789") != "" {
+			return NoKey, 0, fmt.Errorf("invalid index key [%s] in %q", raw, input)
+		}
+		return IntKey(value), end + 1, nil
+	}
+}
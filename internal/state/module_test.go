@@ -0,0 +1,80 @@
+/*
+MIT License
+
+# Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state_test
+
+import (
+	"testing"
+
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseModulePath(t *testing.T) {
+	t.Run("Empty string should returns the root module", func(t *testing.T) {
+		path, err := state.ParseModulePath("")
+		assert.Nil(t, err)
+		assert.True(t, path.IsRoot())
+	})
+
+	t.Run("Module paths with instance keys should be parsed", func(t *testing.T) {
+		path, err := state.ParseModulePath(`module.app["eu.west"].module.db[0].module.replica[*]`)
+		assert.Nil(t, err)
+		assert.Equal(t, state.ModulePath{
+			{Name: "app", Key: state.StringKey("eu.west")},
+			{Name: "db", Key: state.IntKey(0)},
+			{Name: "replica", Key: state.AnyKey},
+		}, path)
+		assert.Equal(t, `module.app["eu.west"].module.db[0].module.replica[*]`, path.String())
+	})
+
+	t.Run("Invalid module paths should returns an error", func(t *testing.T) {
+		for _, input := range []string{"module", "app", "module.app.aws_instance", `module.app["eu]`, "module.app[eu]", "module.app[-1]", "module.app[0", "module.app[0]x", "module..app"} {
+			_, err := state.ParseModulePath(input)
+			assert.NotNil(t, err, input)
+		}
+	})
+}
+
+func TestModulePath_Matches(t *testing.T) {
+	pattern := state.ModulePath{{Name: "app", Key: state.AnyKey}}
+
+	assert.True(t, pattern.Matches(state.ModulePath{{Name: "app", Key: state.StringKey("eu")}}))
+	assert.True(t, pattern.Matches(state.ModulePath{{Name: "app", Key: state.IntKey(1)}}))
+	assert.False(t, pattern.Matches(state.ModulePath{{Name: "web", Key: state.IntKey(1)}}))
+	assert.False(t, pattern.Matches(state.ModulePath{{Name: "app"}, {Name: "db"}}))
+
+	exact := state.ModulePath{{Name: "app", Key: state.StringKey("eu")}}
+	assert.True(t, exact.Matches(state.ModulePath{{Name: "app", Key: state.StringKey("eu")}}))
+	assert.False(t, exact.Matches(state.ModulePath{{Name: "app", Key: state.StringKey("us")}}))
+
+	glob := state.ModulePath{{Name: "a*"}}
+	assert.True(t, glob.Matches(state.ModulePath{{Name: "app", Key: state.StringKey("eu")}}))
+	assert.True(t, glob.Matches(state.ModulePath{{Name: "app", Key: state.IntKey(0)}}))
+	assert.True(t, glob.Matches(state.ModulePath{{Name: "app"}}))
+	assert.False(t, state.ModulePath{{Name: "app"}}.Matches(state.ModulePath{{Name: "app", Key: state.IntKey(0)}}))
+
+	path := state.ModulePath{{Name: "app", Key: state.StringKey("eu")}, {Name: "db"}}
+	assert.True(t, path.HasPrefix(pattern))
+	assert.False(t, pattern.HasPrefix(path))
+}
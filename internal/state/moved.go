@@ -0,0 +1,161 @@
+// Package state list all related resource to terraform state file
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state
+
+import (
+	"fmt"
+)
+
+// Move is the relocation of a resource instance from an address to another one
+type Move struct {
+	From Address
+	To   Address
+}
+
+// String renders the move as a terraform moved block
+func (m Move) String() string {
+	return fmt.Sprintf("moved {\n  from = %s\n  to   = %s\n}\n", m.From, m.To)
+}
+
+// MovesFor returns the moves relocating every instance of resource to newLocation. Instance
+// keys are kept unchanged. Deposed objects follow the move of their instance. As terraform
+// rejects data sources in moved blocks, no move is returned for them.
+func MovesFor(resource TerraformResource, newLocation Address) []Move {
+	if resource.Mode == DataMode {
+		return []Move{}
+	}
+	moves := make([]Move, 0, len(resource.Instances))
+	for _, instance := range resource.Instances {
+		if instance.Deposed != "" {
+			continue
+		}
+		moves = append(moves, Move{
+			From: resource.Address(instance.IndexKey),
+			To:   newLocation.WithKey(instance.IndexKey),
+		})
+	}
+	return moves
+}
+
+// Moves returns the moves of every instance of the managed resources selected by mapping
+func (s TerraformState) Moves(mapping Mapping) ([]Move, error) {
+	moves := []Move{}
+	for _, resource := range s.ListResources(mapping) {
+		if resource.Mode == DataMode {
+			continue
+		}
+		target, err := mapping.Target(resource)
+		if err != nil {
+			return nil, err
+		}
+		moves = append(moves, MovesFor(resource, target)...)
+	}
+	return moves, nil
+}
+
+// MovesByModule returns the moves of the resources selected by mapping like Moves, except
+// that a module instance whose resources are all moved to the same new module is moved with
+// a single module move. Modules partially selected, for instance because of exclude filters,
+// keep one move per resource instance. Data sources are never moved on their own, so a module
+// whose managed resources are all selected is moved whole.
+func (s TerraformState) MovesByModule(mapping Mapping) ([]Move, error) {
+	moduleMapping, ok := mapping.(ModuleMapping)
+	if !ok {
+		return s.Moves(mapping)
+	}
+
+	type moduleGroup struct {
+		move      Move
+		moves     []Move
+		instances int
+	}
+	groups := map[string]*moduleGroup{}
+	order := []interface{}{}
+
+	for _, resource := range s.ListResources(mapping) {
+		if resource.Mode == DataMode {
+			continue
+		}
+		target, err := mapping.Target(resource)
+		if err != nil {
+			return nil, err
+		}
+		moves := MovesFor(resource, target)
+
+		from, to, ok, err := moduleMapping.ModuleTarget(resource)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			order = append(order, moves)
+			continue
+		}
+
+		key := from.String()
+		group, found := groups[key]
+		if !found {
+			group = &moduleGroup{move: Move{From: ModuleAddress(from), To: ModuleAddress(to)}}
+			groups[key] = group
+			order = append(order, group)
+		}
+		if !group.move.To.Equal(ModuleAddress(to)) {
+			return nil, fmt.Errorf("module %s is moved to both %s and %s", from, group.move.To, ModuleAddress(to))
+		}
+		group.moves = append(group.moves, moves...)
+		group.instances += len(resource.Instances)
+	}
+
+	output := []Move{}
+	for _, item := range order {
+		switch value := item.(type) {
+		case []Move:
+			output = append(output, value...)
+		case *moduleGroup:
+			if value.instances == s.countInstances(value.move.From.Module) {
+				output = append(output, value.move)
+			} else {
+				output = append(output, value.moves...)
+			}
+		}
+	}
+	return output, nil
+}
+
+// countInstances returns the number of managed resource instances in the module and its
+// nested modules
+func (s TerraformState) countInstances(module ModulePath) int {
+	count := 0
+	for _, resource := range s.Resources {
+		if resource.Mode == ManagedMode && resource.ModulePath().HasPrefix(module) {
+			count += len(resource.Instances)
+		}
+	}
+	return count
+}
+
+// GenerateMovedStatement generates terraform moved statement for a resource to a newLocation
+func GenerateMovedStatement(resource TerraformResource, newLocation Address) string {
+	return MovedBlocks{}.Format(MovesFor(resource, newLocation))
+}
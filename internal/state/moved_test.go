@@ -0,0 +1,203 @@
+/*
+MIT License
+
+# Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state_test
+
+import (
+	"testing"
+
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateMovedStatement(t *testing.T) {
+	target := state.Address{Mode: state.ManagedMode, Type: "aws_instance", Name: "app"}
+
+	t.Run("Should returns an empty string when resource has no instance", func(t *testing.T) {
+		resource := state.TerraformResource{Mode: "managed", Type: "aws_instance", Name: "web"}
+		assert.Equal(t, "", state.GenerateMovedStatement(resource, target))
+	})
+
+	t.Run("Should returns a moved statement without index for a single instance", func(t *testing.T) {
+		resource := state.TerraformResource{
+			Module:    "module.test",
+			Mode:      "managed",
+			Type:      "aws_instance",
+			Name:      "web",
+			Instances: []state.TerraformResourceValue{{}},
+		}
+		expected := "moved {\n  from = module.test.aws_instance.web\n  to   = aws_instance.app\n}\n\n"
+		assert.Equal(t, expected, state.GenerateMovedStatement(resource, target))
+	})
+
+	t.Run("Should returns one moved statement per instance with its index key", func(t *testing.T) {
+		resource := state.TerraformResource{
+			Mode: "managed",
+			Type: "aws_instance",
+			Name: "web",
+			Instances: []state.TerraformResourceValue{
+				{IndexKey: state.IntKey(0)},
+				{IndexKey: state.StringKey("a")},
+			},
+		}
+		expected := "moved {\n  from = aws_instance.web[0]\n  to   = aws_instance.app[0]\n}\n\n" +
+			"moved {\n  from = aws_instance.web[\"a\"]\n  to   = aws_instance.app[\"a\"]\n}\n\n"
+		assert.Equal(t, expected, state.GenerateMovedStatement(resource, target))
+	})
+}
+
+func TestMovesFor(t *testing.T) {
+	resource := state.TerraformResource{
+		Module:    `module.app["eu"]`,
+		Mode:      "managed",
+		Type:      "aws_s3_bucket",
+		Name:      "logs",
+		Instances: []state.TerraformResourceValue{{IndexKey: state.StringKey("a")}},
+	}
+	target, err := state.ParseAddress("module.storage.aws_s3_bucket.archive")
+	assert.Nil(t, err)
+
+	moves := state.MovesFor(resource, target)
+	assert.Len(t, moves, 1)
+	assert.Equal(t, `module.app["eu"].aws_s3_bucket.logs["a"]`, moves[0].From.String())
+	assert.Equal(t, `module.storage.aws_s3_bucket.archive["a"]`, moves[0].To.String())
+
+	t.Run("Data sources should not be moved", func(t *testing.T) {
+		resource := state.TerraformResource{Mode: state.DataMode, Type: "aws_caller_identity", Name: "current", Instances: []state.TerraformResourceValue{{}}}
+		assert.Empty(t, state.MovesFor(resource, target))
+	})
+}
+
+func TestMoves(t *testing.T) {
+	t.Run("Selected data sources should not be moved", func(t *testing.T) {
+		mapping, err := state.NewLocationMapping("module.legacy_vpc", "module.vpc")
+		assert.Nil(t, err)
+
+		moves, err := modulesState().Moves(mapping)
+		assert.Nil(t, err)
+		assert.Len(t, moves, 3)
+		for _, move := range moves {
+			assert.Equal(t, state.ManagedMode, move.From.Mode, move.From.String())
+		}
+	})
+}
+
+func modulesState() state.TerraformState {
+	instance := []state.TerraformResourceValue{{}}
+	return state.TerraformState{
+		Version: 4,
+		Resources: []state.TerraformResource{
+			{Module: "module.legacy_vpc", Mode: state.ManagedMode, Type: "aws_vpc", Name: "main", Instances: instance},
+			{Module: "module.legacy_vpc.module.subnets", Mode: state.ManagedMode, Type: "aws_subnet", Name: "private", Instances: []state.TerraformResourceValue{{IndexKey: state.IntKey(0)}, {IndexKey: state.IntKey(1)}}},
+			{Module: "module.legacy_vpc", Mode: state.DataMode, Type: "aws_region", Name: "current", Instances: instance},
+			{Module: `module.app["eu"]`, Mode: state.ManagedMode, Type: "aws_s3_bucket", Name: "logs", Instances: instance},
+			{Module: `module.app["us"]`, Mode: state.ManagedMode, Type: "aws_s3_bucket", Name: "logs", Instances: instance},
+			{Mode: state.ManagedMode, Type: "aws_sqs_queue", Name: "jobs", Instances: instance},
+		},
+	}
+}
+
+func TestMovesByModule(t *testing.T) {
+	t.Run("Whole module should be moved with a single moved block", func(t *testing.T) {
+		mapping, err := state.NewLocationMapping("module.legacy_vpc", "module.vpc")
+		assert.Nil(t, err)
+
+		moves, err := modulesState().MovesByModule(mapping)
+		assert.Nil(t, err)
+		assert.Len(t, moves, 1)
+		assert.True(t, moves[0].From.IsModule())
+		assert.Equal(t, "moved {\n  from = module.legacy_vpc\n  to   = module.vpc\n}\n", moves[0].String())
+	})
+
+	t.Run("Keyed module instances should be moved one by one", func(t *testing.T) {
+		mapping, err := state.NewLocationMapping("module.app[*]", "module.storage[*]")
+		assert.Nil(t, err)
+
+		moves, err := modulesState().MovesByModule(mapping)
+		assert.Nil(t, err)
+		assert.Len(t, moves, 2)
+		assert.Equal(t, `module.app["eu"]`, moves[0].From.String())
+		assert.Equal(t, `module.storage["eu"]`, moves[0].To.String())
+		assert.Equal(t, `module.app["us"]`, moves[1].From.String())
+		assert.Equal(t, `module.storage["us"]`, moves[1].To.String())
+	})
+
+	t.Run("Module instance should be moved to a module without key", func(t *testing.T) {
+		mapping, err := state.NewLocationMapping(`module.app["eu"]`, "module.eu")
+		assert.Nil(t, err)
+
+		moves, err := modulesState().MovesByModule(mapping)
+		assert.Nil(t, err)
+		assert.Len(t, moves, 1)
+		assert.Equal(t, "module.eu", moves[0].To.String())
+	})
+
+	t.Run("Partially selected module should fallback to resource moves", func(t *testing.T) {
+		mapping, err := state.NewLocationMapping("module.legacy_vpc", "module.vpc")
+		assert.Nil(t, err)
+		filters, err := state.NewFilterSet(nil, []string{"module.legacy_vpc.module.subnets"})
+		assert.Nil(t, err)
+
+		moves, err := modulesState().MovesByModule(state.RestrictMapping(mapping, filters))
+		assert.Nil(t, err)
+		assert.Len(t, moves, 1)
+		assert.Equal(t, "module.legacy_vpc.aws_vpc.main", moves[0].From.String())
+		assert.Equal(t, "module.vpc.aws_vpc.main", moves[0].To.String())
+	})
+
+	t.Run("Module should be moved whole when only its data sources are not selected", func(t *testing.T) {
+		mapping, err := state.NewLocationMapping("module.legacy_vpc", "module.vpc")
+		assert.Nil(t, err)
+		filters, err := state.NewFilterSet(nil, []string{"data.*.*"})
+		assert.Nil(t, err)
+
+		moves, err := modulesState().MovesByModule(state.RestrictMapping(mapping, filters))
+		assert.Nil(t, err)
+		assert.Len(t, moves, 1)
+		assert.True(t, moves[0].From.IsModule())
+	})
+
+	t.Run("Resource moves of a partially selected module should not include data sources", func(t *testing.T) {
+		mapping, err := state.NewLocationMapping("module.legacy_vpc", "module.vpc")
+		assert.Nil(t, err)
+		filters, err := state.NewFilterSet(nil, []string{"module.legacy_vpc.aws_vpc.main"})
+		assert.Nil(t, err)
+
+		moves, err := modulesState().MovesByModule(state.RestrictMapping(mapping, filters))
+		assert.Nil(t, err)
+		assert.Len(t, moves, 2)
+		for _, move := range moves {
+			assert.Equal(t, state.ManagedMode, move.From.Mode, move.From.String())
+		}
+	})
+
+	t.Run("Resource mapping should returns resource moves", func(t *testing.T) {
+		mapping, err := state.NewLocationMapping("aws_sqs_queue.jobs", "module.queues.aws_sqs_queue.jobs")
+		assert.Nil(t, err)
+
+		moves, err := modulesState().MovesByModule(mapping)
+		assert.Nil(t, err)
+		assert.Len(t, moves, 1)
+		assert.False(t, moves[0].From.IsModule())
+	})
+}
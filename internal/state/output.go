@@ -0,0 +1,212 @@
+// Package state list all related resource to terraform state file
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const sensitiveValue = "(sensitive value)"
+
+// Kinds of terraform types
+const (
+	CtyKindString  = "string"
+	CtyKindNumber  = "number"
+	CtyKindBool    = "bool"
+	CtyKindDynamic = "dynamic"
+	CtyKindList    = "list"
+	CtyKindSet     = "set"
+	CtyKindMap     = "map"
+	CtyKindObject  = "object"
+	CtyKindTuple   = "tuple"
+)
+
+// CtyType represents the type of a terraform value as serialised in state files, for
+// instance "string", ["list","string"] or ["object",{"name":"string"}].
+type CtyType struct {
+	Kind       string
+	Element    *CtyType
+	Attributes map[string]CtyType
+	// Optional lists the optional attributes of an object type, given as third element of
+	// the type expression (["object",{"name":"string"},["name"]])
+	Optional []string
+	Elements []CtyType
+}
+
+var (
+	// CtyString is the terraform string type
+	CtyString = CtyType{Kind: CtyKindString}
+	// CtyNumber is the terraform number type
+	CtyNumber = CtyType{Kind: CtyKindNumber}
+	// CtyBool is the terraform bool type
+	CtyBool = CtyType{Kind: CtyKindBool}
+	// CtyDynamic is the terraform type of values whose type is unknown
+	CtyDynamic = CtyType{Kind: CtyKindDynamic}
+)
+
+// String renders the type as a terraform type constraint, for instance list(string)
+func (t CtyType) String() string {
+	switch t.Kind {
+	case CtyKindDynamic:
+		return "any"
+	case CtyKindList, CtyKindSet, CtyKindMap:
+		element := CtyDynamic
+		if t.Element != nil {
+			element = *t.Element
+		}
+		return fmt.Sprintf("%s(%s)", t.Kind, element)
+	case CtyKindObject:
+		names := make([]string, 0, len(t.Attributes))
+		for name := range t.Attributes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		attributes := make([]string, 0, len(names))
+		for _, name := range names {
+			attributes = append(attributes, fmt.Sprintf("%s = %s", name, t.Attributes[name]))
+		}
+		return fmt.Sprintf("object({%s})", strings.Join(attributes, ", "))
+	case CtyKindTuple:
+		elements := make([]string, 0, len(t.Elements))
+		for _, element := range t.Elements {
+			elements = append(elements, element.String())
+		}
+		return fmt.Sprintf("tuple([%s])", strings.Join(elements, ", "))
+	default:
+		return t.Kind
+	}
+}
+
+// UnmarshalJSON decodes a cty type expression
+func (t *CtyType) UnmarshalJSON(data []byte) error {
+	var primitive string
+	if err := json.Unmarshal(data, &primitive); err == nil {
+		switch primitive {
+		case CtyKindString, CtyKindNumber, CtyKindBool, CtyKindDynamic:
+			*t = CtyType{Kind: primitive}
+			return nil
+		}
+		return fmt.Errorf("unknown type %q", primitive)
+	}
+
+	var expression []json.RawMessage
+	if err := json.Unmarshal(data, &expression); err != nil || len(expression) < 2 {
+		return fmt.Errorf("invalid type expression %s", data)
+	}
+
+	var kind string
+	if err := json.Unmarshal(expression[0], &kind); err != nil {
+		return fmt.Errorf("invalid type expression %s", data)
+	}
+
+	switch kind {
+	case CtyKindList, CtyKindSet, CtyKindMap:
+		var element CtyType
+		if err := json.Unmarshal(expression[1], &element); err != nil {
+			return err
+		}
+		*t = CtyType{Kind: kind, Element: &element}
+	case CtyKindObject:
+		var attributes map[string]CtyType
+		if err := json.Unmarshal(expression[1], &attributes); err != nil {
+			return err
+		}
+		*t = CtyType{Kind: kind, Attributes: attributes}
+		if len(expression) > 2 {
+			if err := json.Unmarshal(expression[2], &t.Optional); err != nil {
+				return fmt.Errorf("invalid optional attributes in type expression %s", data)
+			}
+		}
+	case CtyKindTuple:
+		var elements []CtyType
+		if err := json.Unmarshal(expression[1], &elements); err != nil {
+			return err
+		}
+		*t = CtyType{Kind: kind, Elements: elements}
+	default:
+		return fmt.Errorf("unknown type %q", kind)
+	}
+
+	return nil
+}
+
+// MarshalJSON encodes the type as a cty type expression
+func (t CtyType) MarshalJSON() ([]byte, error) {
+	switch t.Kind {
+	case CtyKindList, CtyKindSet, CtyKindMap:
+		element := CtyDynamic
+		if t.Element != nil {
+			element = *t.Element
+		}
+		return json.Marshal([]interface{}{t.Kind, element})
+	case CtyKindObject:
+		attributes := t.Attributes
+		if attributes == nil {
+			attributes = map[string]CtyType{}
+		}
+		if len(t.Optional) > 0 {
+			return json.Marshal([]interface{}{t.Kind, attributes, t.Optional})
+		}
+		return json.Marshal([]interface{}{t.Kind, attributes})
+	case CtyKindTuple:
+		elements := t.Elements
+		if elements == nil {
+			elements = []CtyType{}
+		}
+		return json.Marshal([]interface{}{t.Kind, elements})
+	case "":
+		return json.Marshal(CtyKindDynamic)
+	default:
+		return json.Marshal(t.Kind)
+	}
+}
+
+// DisplayValue renders the output value as compact json. Sensitive values are redacted
+// unless showSensitive is true.
+func (o TerraformOutputValue) DisplayValue(showSensitive bool) string {
+	if o.Sensitive && !showSensitive {
+		return sensitiveValue
+	}
+
+	rendered, err := json.Marshal(o.Value)
+	if err != nil {
+		return fmt.Sprintf("%v", o.Value)
+	}
+	return string(rendered)
+}
+
+// OutputNames returns the names of the outputs found in the state, sorted alphabetically
+func (s TerraformState) OutputNames() []string {
+	names := make([]string, 0, len(s.Outputs))
+	for name := range s.Outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
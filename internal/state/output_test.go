@@ -0,0 +1,100 @@
+/*
+MIT License
+
+# Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCtyType_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: `"string"`, expected: "string"},
+		{input: `"number"`, expected: "number"},
+		{input: `"bool"`, expected: "bool"},
+		{input: `"dynamic"`, expected: "any"},
+		{input: `["list","string"]`, expected: "list(string)"},
+		{input: `["set","number"]`, expected: "set(number)"},
+		{input: `["map",["list","string"]]`, expected: "map(list(string))"},
+		{input: `["object",{"name":"string","ports":["list","number"]}]`, expected: "object({name = string, ports = list(number)})"},
+		{input: `["object",{"name":"string"},["name"]]`, expected: "object({name = string})"},
+		{input: `["tuple",["string","bool"]]`, expected: "tuple([string, bool])"},
+	}
+
+	for _, test := range tests {
+		var ctyType state.CtyType
+		assert.Nil(t, json.Unmarshal([]byte(test.input), &ctyType), test.input)
+		assert.Equal(t, test.expected, ctyType.String(), test.input)
+	}
+
+	t.Run("Invalid type expressions should returns an error", func(t *testing.T) {
+		for _, input := range []string{`"text"`, `["list"]`, `["array","string"]`, `1`, `{}`} {
+			var ctyType state.CtyType
+			assert.NotNil(t, json.Unmarshal([]byte(input), &ctyType), input)
+		}
+	})
+}
+
+func TestCtyType_MarshalJSON(t *testing.T) {
+	for _, input := range []string{`"string"`, `["list","string"]`, `["object",{"a":["map","bool"]}]`, `["object",{"a":"string","b":"number"},["b"]]`, `["tuple",["string","number"]]`} {
+		var ctyType state.CtyType
+		assert.Nil(t, json.Unmarshal([]byte(input), &ctyType))
+
+		output, err := json.Marshal(ctyType)
+		assert.Nil(t, err)
+		assert.JSONEq(t, input, string(output))
+	}
+}
+
+func TestTerraformState_Outputs(t *testing.T) {
+	input := `{
+  "version": 4,
+  "outputs": {
+    "name": {"value": "bucket", "type": "string"},
+    "ports": {"value": [80, 443], "type": ["list", "number"]},
+    "tags": {"value": {"team": "payments"}, "type": ["map", "string"]},
+    "password": {"value": "secret", "type": "string", "sensitive": true}
+  }
+}`
+	got, err := state.FromReader(strings.NewReader(input))
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"name", "password", "ports", "tags"}, got.OutputNames())
+	assert.Equal(t, "list(number)", got.Outputs["ports"].Type.String())
+	assert.Equal(t, `"bucket"`, got.Outputs["name"].DisplayValue(false))
+	assert.Equal(t, `[80,443]`, got.Outputs["ports"].DisplayValue(false))
+	assert.Equal(t, `{"team":"payments"}`, got.Outputs["tags"].DisplayValue(false))
+
+	t.Run("Sensitive outputs should be redacted", func(t *testing.T) {
+		assert.Equal(t, "(sensitive value)", got.Outputs["password"].DisplayValue(false))
+		assert.Equal(t, `"secret"`, got.Outputs["password"].DisplayValue(true))
+	})
+}
@@ -0,0 +1,340 @@
+// Package state list all related resource to terraform state file
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Actions of resource changes in terraform plans
+const (
+	ActionCreate = "create"
+	ActionDelete = "delete"
+)
+
+// Weights of the attributes compared to pair deleted and created resources
+const (
+	nameWeight      = 3
+	tagWeight       = 2
+	referenceWeight = 2
+	attributeWeight = 1
+)
+
+// nameAttributes are attributes naming a resource in its provider
+var nameAttributes = map[string]bool{
+	"name":               true,
+	"name_prefix":        true,
+	"bucket":             true,
+	"function_name":      true,
+	"identifier":         true,
+	"cluster_identifier": true,
+	"display_name":       true,
+	"title":              true,
+}
+
+// ignoredAttributes are computed attributes which can not be compared
+var ignoredAttributes = map[string]bool{
+	"id":       true,
+	"arn":      true,
+	"tags_all": true,
+}
+
+// Plan is a terraform plan as written by terraform show -json
+type Plan struct {
+	FormatVersion    string           `json:"format_version"`
+	TerraformVersion string           `json:"terraform_version"`
+	ResourceChanges  []ResourceChange `json:"resource_changes"`
+}
+
+// ResourceChange is a change of a resource instance planned by terraform
+type ResourceChange struct {
+	Address string `json:"address"`
+	Mode    string `json:"mode"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Deposed string `json:"deposed"`
+	Change  struct {
+		Actions         []string               `json:"actions"`
+		Before          map[string]interface{} `json:"before"`
+		After           map[string]interface{} `json:"after"`
+		AfterUnknown    interface{}            `json:"after_unknown"`
+		BeforeSensitive interface{}            `json:"before_sensitive"`
+		AfterSensitive  interface{}            `json:"after_sensitive"`
+	} `json:"change"`
+}
+
+// Suggestion is a move pairing a resource instance planned for deletion with a resource
+// instance planned for creation, with a confidence between 0 and 1. Alternatives are the
+// other instances planned for creation which could be paired with the deleted instance, by
+// decreasing confidence.
+type Suggestion struct {
+	Move         Move
+	Confidence   float64
+	Alternatives []Suggestion
+}
+
+// PlanFromReader reads a terraform plan written by terraform show -json
+func PlanFromReader(reader io.Reader) (*Plan, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := Plan{}
+	if err := decodeJSON(data, &plan); err != nil {
+		return nil, fmt.Errorf("malformed terraform plan: %w", err)
+	}
+	if major, _, _ := strings.Cut(plan.FormatVersion, "."); major != ShowFormatVersion {
+		return nil, fmt.Errorf("unsupported terraform plan format %q (expected %s.x)", plan.FormatVersion, ShowFormatVersion)
+	}
+	probe := struct {
+		PlannedValues *json.RawMessage `json:"planned_values"`
+	}{}
+	if err := json.Unmarshal(data, &probe); err != nil || probe.PlannedValues == nil {
+		return nil, errors.New("terraform show document of a state instead of a plan")
+	}
+	return &plan, nil
+}
+
+// PlanFromFile reads a terraform plan written by terraform show -json from a file
+func PlanFromFile(path string) (*Plan, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening terraform plan file %s - %w", path, err)
+	}
+	defer file.Close()
+
+	plan, err := PlanFromReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return plan, nil
+}
+
+// hasActions returns true if the change is made of the given actions only
+func (c ResourceChange) hasActions(actions ...string) bool {
+	return c.Deposed == "" && c.Mode == ManagedMode && reflect.DeepEqual(c.Change.Actions, actions)
+}
+
+// Suggestions pairs resource instances planned for deletion with resource instances of the
+// same type planned for creation, as when a resource is renamed without moved block. Every
+// pair is given a confidence from the attributes found with the same value on both sides:
+// names and tags count more than references to other objects (*_id, *_arn), which count more
+// than other attributes. Attributes set on one side only count as different values, as do
+// attributes unknown until apply, while sensitive attributes are ignored. Each instance is part
+// of at most one suggestion, the most confident pairs being selected first, the other
+// candidates of the deleted instance being kept as alternatives. Suggestions are sorted by
+// decreasing confidence. Deleted instances not found in the state s are ignored.
+func (p Plan) Suggestions(s TerraformState) ([]Suggestion, error) {
+	existing := map[string]bool{}
+	for _, addresses := range s.instanceAddresses() {
+		for _, address := range addresses {
+			existing[address.String()] = true
+		}
+	}
+
+	deleted := []ResourceChange{}
+	created := []ResourceChange{}
+	for _, change := range p.ResourceChanges {
+		switch {
+		case change.hasActions(ActionDelete):
+			address, err := ParseAddress(change.Address)
+			if err != nil {
+				return nil, err
+			}
+			if existing[address.String()] {
+				deleted = append(deleted, change)
+			}
+		case change.hasActions(ActionCreate):
+			created = append(created, change)
+		}
+	}
+
+	candidates := []Suggestion{}
+	for _, from := range deleted {
+		for _, to := range created {
+			if from.Type != to.Type {
+				continue
+			}
+			fromAddress, err := ParseAddress(from.Address)
+			if err != nil {
+				return nil, err
+			}
+			toAddress, err := ParseAddress(to.Address)
+			if err != nil {
+				return nil, err
+			}
+			candidates = append(candidates, Suggestion{
+				Move:       Move{From: fromAddress, To: toAddress},
+				Confidence: similarity(from, to),
+			})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Confidence > candidates[j].Confidence
+	})
+
+	suggestions := []Suggestion{}
+	paired := map[string]bool{}
+	for _, candidate := range candidates {
+		from, to := "from "+candidate.Move.From.String(), "to "+candidate.Move.To.String()
+		if candidate.Confidence == 0 || paired[from] || paired[to] {
+			continue
+		}
+		paired[from], paired[to] = true, true
+		suggestions = append(suggestions, candidate)
+	}
+
+	for index, suggestion := range suggestions {
+		for _, candidate := range candidates {
+			if candidate.Confidence > 0 && candidate.Move.From.Equal(suggestion.Move.From) && !candidate.Move.To.Equal(suggestion.Move.To) {
+				suggestions[index].Alternatives = append(suggestions[index].Alternatives, candidate)
+			}
+		}
+	}
+	return suggestions, nil
+}
+
+// similarity returns the weighted share of the attributes, set before the deletion or after
+// the creation, having the same value on both sides. Unknown values never match.
+func similarity(deleted ResourceChange, created ResourceChange) float64 {
+	sensitive := sensitiveNames(deleted.Change.BeforeSensitive)
+	for name := range sensitiveNames(created.Change.AfterSensitive) {
+		sensitive[name] = true
+	}
+	unknown := sensitiveNames(created.Change.AfterUnknown)
+
+	matched, total := 0, 0
+	compare := func(weight int, before interface{}, after interface{}, unknown bool) {
+		if isEmptyValue(before) && isEmptyValue(after) && !unknown {
+			return
+		}
+		total += weight
+		if !unknown && reflect.DeepEqual(before, after) {
+			matched += weight
+		}
+	}
+
+	for name := range unionKeys(deleted.Change.Before, created.Change.After) {
+		if ignoredAttributes[name] || sensitive[name] {
+			continue
+		}
+		before, after := deleted.Change.Before[name], created.Change.After[name]
+		switch {
+		case name == "tags":
+			beforeTags, _ := before.(map[string]interface{})
+			afterTags, _ := after.(map[string]interface{})
+			for key := range unionKeys(beforeTags, afterTags) {
+				compare(tagWeight, beforeTags[key], afterTags[key], unknown[name])
+			}
+		case nameAttributes[name]:
+			compare(nameWeight, before, after, unknown[name])
+		case isReferenceAttribute(name):
+			compare(referenceWeight, before, after, unknown[name])
+		default:
+			compare(attributeWeight, before, after, unknown[name])
+		}
+	}
+
+	if total == 0 {
+		return 0
+	}
+	return float64(matched) / float64(total)
+}
+
+// isReferenceAttribute returns true for attributes referencing other objects, like vpc_id
+func isReferenceAttribute(name string) bool {
+	for _, suffix := range []string{"_id", "_ids", "_arn", "_arns"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// sensitiveNames returns the names of the attributes holding a true value, at any depth, in
+// the sensitive or unknown values of a change
+func sensitiveNames(values interface{}) map[string]bool {
+	names := map[string]bool{}
+	if attributes, ok := values.(map[string]interface{}); ok {
+		for name, value := range attributes {
+			if containsTrue(value) {
+				names[name] = true
+			}
+		}
+	}
+	return names
+}
+
+func containsTrue(value interface{}) bool {
+	switch value := value.(type) {
+	case bool:
+		return value
+	case map[string]interface{}:
+		for _, element := range value {
+			if containsTrue(element) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, element := range value {
+			if containsTrue(element) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isEmptyValue(value interface{}) bool {
+	switch value := value.(type) {
+	case nil:
+		return true
+	case string:
+		return value == ""
+	case map[string]interface{}:
+		return len(value) == 0
+	case []interface{}:
+		return len(value) == 0
+	}
+	return false
+}
+
+func unionKeys(maps ...map[string]interface{}) map[string]bool {
+	keys := map[string]bool{}
+	for _, values := range maps {
+		for key := range values {
+			keys[key] = true
+		}
+	}
+	return keys
+}
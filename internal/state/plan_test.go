@@ -0,0 +1,164 @@
+/*
+MIT License
+
+# Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/stretchr/testify/assert"
+)
+
+const planDocument = `{
+  "format_version": "1.2",
+  "terraform_version": "1.5.7",
+  "planned_values": {"root_module": {}},
+  "resource_changes": [
+    {
+      "address": "aws_instance.web[0]", "mode": "managed", "type": "aws_instance", "name": "web", "index": 0,
+      "change": {
+        "actions": ["delete"],
+        "before": {"id": "i-1", "ami": "ami-1", "instance_type": "m5.large", "subnet_id": "subnet-a", "tags": {"Name": "payments", "team": "payments"}, "user_data": "a"},
+        "after": null, "before_sensitive": {"user_data": true}
+      }
+    },
+    {
+      "address": "aws_instance.web[1]", "mode": "managed", "type": "aws_instance", "name": "web", "index": 1,
+      "change": {
+        "actions": ["delete"],
+        "before": {"id": "i-2", "ami": "ami-1", "instance_type": "t3.micro", "subnet_id": "subnet-b", "tags": {"Name": "core", "team": "core"}, "user_data": "b"},
+        "after": null, "before_sensitive": {"user_data": true}
+      }
+    },
+    {
+      "address": "aws_instance.gone", "mode": "managed", "type": "aws_instance", "name": "gone",
+      "change": {"actions": ["delete"], "before": {"id": "i-3", "ami": "ami-1", "instance_type": "t3.micro"}, "after": null}
+    },
+    {
+      "address": "aws_instance.db", "mode": "managed", "type": "aws_instance", "name": "db",
+      "change": {"actions": ["delete", "create"], "before": {"id": "i-4"}, "after": {"ami": "ami-1"}}
+    },
+    {
+      "address": "aws_instance.app[\"core\"]", "mode": "managed", "type": "aws_instance", "name": "app", "index": "core",
+      "change": {
+        "actions": ["create"], "before": null,
+        "after": {"ami": "ami-1", "instance_type": "t3.micro", "subnet_id": null, "tags": {"Name": "core", "team": "core"}, "user_data": "a"},
+        "after_unknown": {"id": true, "subnet_id": true}, "after_sensitive": {"user_data": true}
+      }
+    },
+    {
+      "address": "aws_instance.app[\"payments\"]", "mode": "managed", "type": "aws_instance", "name": "app", "index": "payments",
+      "change": {
+        "actions": ["create"], "before": null,
+        "after": {"ami": "ami-2", "instance_type": "m5.large", "subnet_id": "subnet-a", "tags": {"Name": "payments", "team": "payments"}, "user_data": "a"},
+        "after_unknown": {"id": true}, "after_sensitive": {"user_data": true}
+      }
+    },
+    {
+      "address": "aws_s3_bucket.logs", "mode": "managed", "type": "aws_s3_bucket", "name": "logs",
+      "change": {"actions": ["create"], "before": null, "after": {"bucket": "logs"}, "after_unknown": {"id": true}}
+    }
+  ]
+}`
+
+func planState() state.TerraformState {
+	return state.TerraformState{Version: 4, Resources: []state.TerraformResource{
+		{Mode: state.ManagedMode, Type: "aws_instance", Name: "web", Instances: []state.TerraformResourceValue{{IndexKey: state.IntKey(0)}, {IndexKey: state.IntKey(1)}}},
+		{Mode: state.ManagedMode, Type: "aws_instance", Name: "db", Instances: []state.TerraformResourceValue{{}}},
+	}}
+}
+
+func TestPlanFromReader(t *testing.T) {
+	t.Run("PlanFromReader should read resource changes", func(t *testing.T) {
+		plan, err := state.PlanFromReader(strings.NewReader(planDocument))
+		assert.Nil(t, err)
+		assert.Len(t, plan.ResourceChanges, 7)
+		assert.Equal(t, []string{state.ActionDelete}, plan.ResourceChanges[0].Change.Actions)
+	})
+
+	t.Run("PlanFromReader should returns an error for states", func(t *testing.T) {
+		_, err := state.PlanFromReader(strings.NewReader(`{"format_version": "1.0", "values": {}}`))
+		assert.EqualError(t, err, "terraform show document of a state instead of a plan")
+	})
+
+	t.Run("PlanFromReader should returns an error for unsupported formats", func(t *testing.T) {
+		_, err := state.PlanFromReader(strings.NewReader(`{"version": 4}`))
+		assert.ErrorContains(t, err, "unsupported terraform plan format")
+	})
+}
+
+func TestSuggestions(t *testing.T) {
+	plan, err := state.PlanFromReader(strings.NewReader(planDocument))
+	assert.Nil(t, err)
+
+	suggestions, err := plan.Suggestions(planState())
+	assert.Nil(t, err)
+	assert.Len(t, suggestions, 2)
+
+	t.Run("Suggestions should weight names and tags more than other attributes", func(t *testing.T) {
+		assert.Equal(t, "aws_instance.web[0]", suggestions[0].Move.From.String())
+		assert.Equal(t, `aws_instance.app["payments"]`, suggestions[0].Move.To.String())
+		assert.Equal(t, 7.0/8.0, suggestions[0].Confidence)
+	})
+
+	t.Run("Suggestions should rank the alternatives of deleted instances", func(t *testing.T) {
+		assert.Len(t, suggestions[0].Alternatives, 1)
+		assert.Equal(t, `aws_instance.app["core"]`, suggestions[0].Alternatives[0].Move.To.String())
+		assert.Equal(t, 1.0/8.0, suggestions[0].Alternatives[0].Confidence)
+		assert.Empty(t, suggestions[1].Alternatives)
+	})
+
+	t.Run("Suggestions should count unknown attributes as different and ignore sensitive attributes", func(t *testing.T) {
+		assert.Equal(t, "aws_instance.web[1]", suggestions[1].Move.From.String())
+		assert.Equal(t, `aws_instance.app["core"]`, suggestions[1].Move.To.String())
+		assert.Equal(t, 6.0/8.0, suggestions[1].Confidence)
+	})
+
+	t.Run("Suggestions should compare attributes set before the deletion", func(t *testing.T) {
+		plan, err := state.PlanFromReader(strings.NewReader(`{
+  "format_version": "1.2",
+  "planned_values": {"root_module": {}},
+  "resource_changes": [
+    {
+      "address": "aws_s3_bucket.old", "mode": "managed", "type": "aws_s3_bucket", "name": "old",
+      "change": {"actions": ["delete"], "before": {"id": "logs", "bucket": "logs", "acl": "private", "force_destroy": false}, "after": null}
+    },
+    {
+      "address": "aws_s3_bucket.new", "mode": "managed", "type": "aws_s3_bucket", "name": "new",
+      "change": {"actions": ["create"], "before": null, "after": {"force_destroy": false}, "after_unknown": {"id": true, "bucket": true}}
+    }
+  ]
+}`))
+		assert.Nil(t, err)
+		bucket := state.TerraformState{Version: 4, Resources: []state.TerraformResource{
+			{Mode: state.ManagedMode, Type: "aws_s3_bucket", Name: "old", Instances: []state.TerraformResourceValue{{}}},
+		}}
+
+		suggestions, err := plan.Suggestions(bucket)
+		assert.Nil(t, err)
+		assert.Len(t, suggestions, 1)
+		assert.Equal(t, 1.0/5.0, suggestions[0].Confidence)
+	})
+}
@@ -0,0 +1,206 @@
+// Package state list all related resource to terraform state file
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// DefaultProviderRegistry is the hostname of the public terraform registry
+	DefaultProviderRegistry = "registry.terraform.io"
+	// DefaultProviderNamespace is the namespace of providers declared without source
+	DefaultProviderNamespace = "hashicorp"
+	// LegacyProviderNamespace is the namespace of providers found in terraform 0.12 states
+	LegacyProviderNamespace = "-"
+)
+
+// ProviderAddress is the address of a provider configuration as found in the provider field
+// of state resources, for instance provider["registry.terraform.io/hashicorp/aws"].eu_west
+// or module.network.provider["registry.terraform.io/hashicorp/aws"].
+type ProviderAddress struct {
+	Module    ModulePath
+	Hostname  string
+	Namespace string
+	Type      string
+	Alias     string
+}
+
+// String renders the canonical form of the provider configuration address
+func (p ProviderAddress) String() string {
+	var builder strings.Builder
+	if !p.Module.IsRoot() {
+		builder.WriteString(p.Module.String())
+		builder.WriteString(".")
+	}
+	fmt.Fprintf(&builder, "provider[%s]", quoteString(p.Source()))
+	if p.Alias != "" {
+		builder.WriteString(".")
+		builder.WriteString(p.Alias)
+	}
+	return builder.String()
+}
+
+// Source returns the provider source address: hostname/namespace/type
+func (p ProviderAddress) Source() string {
+	return fmt.Sprintf("%s/%s/%s", p.Hostname, p.Namespace, p.Type)
+}
+
+// ParseProviderAddress parses the address of a provider configuration. Legacy addresses
+// written by terraform 0.12 (provider.aws.eu_west) are also accepted.
+func ParseProviderAddress(input string) (ProviderAddress, error) {
+	steps, err := parseTraversal(input)
+	if err != nil {
+		return ProviderAddress{}, fmt.Errorf("invalid provider address %q: %w", input, err)
+	}
+
+	module, steps, err := parseModuleSteps(steps)
+	if err != nil || len(steps) == 0 || steps[0].name != "provider" {
+		return ProviderAddress{}, fmt.Errorf("invalid provider address %q: expected provider[\"source\"][.alias]", input)
+	}
+
+	address := ProviderAddress{Module: module}
+	switch {
+	case steps[0].key.IsString():
+		if err := address.parseSource(steps[0].key.AsString()); err != nil {
+			return ProviderAddress{}, fmt.Errorf("invalid provider address %q: %w", input, err)
+		}
+		steps = steps[1:]
+	case steps[0].key.IsNone() && len(steps) > 1:
+		address.Hostname = DefaultProviderRegistry
+		address.Namespace = LegacyProviderNamespace
+		address.Type = steps[1].name
+		steps = steps[2:]
+	default:
+		return ProviderAddress{}, fmt.Errorf("invalid provider address %q: expected provider[\"source\"][.alias]", input)
+	}
+
+	switch len(steps) {
+	case 0:
+	case 1:
+		address.Alias = steps[0].name
+	default:
+		return ProviderAddress{}, fmt.Errorf("invalid provider address %q: unexpected %s", input, steps[1])
+	}
+
+	return address, nil
+}
+
+func (p *ProviderAddress) parseSource(source string) error {
+	parts := strings.Split(source, "/")
+	for _, part := range parts {
+		if strings.TrimSpace(part) == "" {
+			return fmt.Errorf("invalid provider source %q", source)
+		}
+	}
+
+	p.Hostname = DefaultProviderRegistry
+	p.Namespace = DefaultProviderNamespace
+	switch len(parts) {
+	case 1:
+		p.Type = parts[0]
+	case 2:
+		p.Namespace, p.Type = parts[0], parts[1]
+	case 3:
+		p.Hostname, p.Namespace, p.Type = parts[0], parts[1], parts[2]
+	default:
+		return fmt.Errorf("invalid provider source %q: expected [hostname/]namespace/type", source)
+	}
+	return nil
+}
+
+// ProviderFilter selects provider configurations from a short specification:
+// [module.name.]...[[hostname/]namespace/]type[.alias], for instance aws, hashicorp/aws.eu_west,
+// registry.terraform.io/hashicorp/aws or module.network.aws. Each part may contain globs.
+// Parts that are not specified match any value, so aws matches every configuration of the
+// aws provider in any module, with or without alias. A trailing dot (aws.) only selects the
+// default configuration, without alias.
+type ProviderFilter struct {
+	// Module is the module declaring the provider configuration, any module when nil
+	Module    ModulePath
+	Hostname  string
+	Namespace string
+	Type      string
+	Alias     string
+	// DefaultOnly selects the default configuration of the provider, without alias
+	DefaultOnly bool
+}
+
+var providerFilterModulePattern = regexp.MustCompile(`^(module\.[^.\[\]/]+(\[[^\]]*\])?\.)+`)
+
+// ParseProviderFilter parses a provider filter specification
+func ParseProviderFilter(input string) (ProviderFilter, error) {
+	filter := ProviderFilter{}
+	source := strings.TrimSpace(input)
+	if prefix := providerFilterModulePattern.FindString(source); prefix != "" {
+		module, err := ParseModulePath(strings.TrimSuffix(prefix, "."))
+		if err != nil {
+			return ProviderFilter{}, fmt.Errorf("invalid provider filter %q: %w", input, err)
+		}
+		filter.Module = module
+		source = source[len(prefix):]
+	}
+
+	if separator := strings.LastIndex(source, "/"); strings.Contains(source[separator+1:], ".") {
+		dot := separator + 1 + strings.Index(source[separator+1:], ".")
+		source, filter.Alias = source[:dot], source[dot+1:]
+		filter.DefaultOnly = filter.Alias == ""
+	}
+
+	parts := strings.Split(source, "/")
+	for _, part := range parts {
+		if part == "" {
+			return ProviderFilter{}, fmt.Errorf("invalid provider filter %q: expected [module.name.][[hostname/]namespace/]type[.alias]", input)
+		}
+	}
+	switch len(parts) {
+	case 1:
+		filter.Type = parts[0]
+	case 2:
+		filter.Namespace, filter.Type = parts[0], parts[1]
+	case 3:
+		filter.Hostname, filter.Namespace, filter.Type = parts[0], parts[1], parts[2]
+	default:
+		return ProviderFilter{}, fmt.Errorf("invalid provider filter %q: expected [module.name.][[hostname/]namespace/]type[.alias]", input)
+	}
+
+	return filter, nil
+}
+
+// Matches returns true if the provider configuration matches the filter
+func (f ProviderFilter) Matches(provider ProviderAddress) bool {
+	if f.Module != nil && !f.Module.Matches(provider.Module) {
+		return false
+	}
+	if f.DefaultOnly && provider.Alias != "" {
+		return false
+	}
+	return matchGlob(f.Hostname, provider.Hostname) &&
+		(matchGlob(f.Namespace, provider.Namespace) || provider.Namespace == LegacyProviderNamespace) &&
+		matchGlob(f.Type, provider.Type) &&
+		matchGlob(f.Alias, provider.Alias)
+}
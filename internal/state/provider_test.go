@@ -0,0 +1,138 @@
+/*
+MIT License
+
+# Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state_test
+
+import (
+	"testing"
+
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProviderAddress(t *testing.T) {
+	t.Run("Provider configuration addresses should be parsed", func(t *testing.T) {
+		provider, err := state.ParseProviderAddress(`provider["registry.terraform.io/hashicorp/aws"].eu_west`)
+		assert.Nil(t, err)
+		assert.Equal(t, state.ProviderAddress{
+			Module:    state.ModulePath{},
+			Hostname:  "registry.terraform.io",
+			Namespace: "hashicorp",
+			Type:      "aws",
+			Alias:     "eu_west",
+		}, provider)
+
+		provider, err = state.ParseProviderAddress(`module.network["eu"].provider["example.com/acme/cloud"]`)
+		assert.Nil(t, err)
+		assert.Equal(t, state.ProviderAddress{
+			Module:    state.ModulePath{{Name: "network", Key: state.StringKey("eu")}},
+			Hostname:  "example.com",
+			Namespace: "acme",
+			Type:      "cloud",
+		}, provider)
+	})
+
+	t.Run("Legacy provider addresses should be parsed", func(t *testing.T) {
+		provider, err := state.ParseProviderAddress("provider.aws.eu_west")
+		assert.Nil(t, err)
+		assert.Equal(t, "aws", provider.Type)
+		assert.Equal(t, "eu_west", provider.Alias)
+		assert.Equal(t, state.LegacyProviderNamespace, provider.Namespace)
+	})
+
+	t.Run("Provider addresses should round trip", func(t *testing.T) {
+		for _, input := range []string{
+			`provider["registry.terraform.io/hashicorp/aws"]`,
+			`provider["registry.terraform.io/hashicorp/aws"].eu_west`,
+			`module.network.provider["registry.terraform.io/hashicorp/null"]`,
+		} {
+			provider, err := state.ParseProviderAddress(input)
+			assert.Nil(t, err, input)
+			assert.Equal(t, input, provider.String())
+		}
+	})
+
+	t.Run("Invalid provider addresses should returns an error", func(t *testing.T) {
+		for _, input := range []string{"", "aws", "provider", `provider[0]`, `provider["a/b/c/d"]`, `provider["//aws"]`, `provider["aws"].a.b`} {
+			_, err := state.ParseProviderAddress(input)
+			assert.NotNil(t, err, input)
+		}
+	})
+}
+
+func TestProviderFilter(t *testing.T) {
+	euWest, _ := state.ParseProviderAddress(`provider["registry.terraform.io/hashicorp/aws"].eu_west`)
+	aws, _ := state.ParseProviderAddress(`module.network.provider["registry.terraform.io/hashicorp/aws"]`)
+	legacy, _ := state.ParseProviderAddress("provider.aws")
+	null, _ := state.ParseProviderAddress(`provider["registry.terraform.io/hashicorp/null"]`)
+
+	tests := []struct {
+		filter   string
+		expected []bool
+	}{
+		{filter: "aws", expected: []bool{true, true, true, false}},
+		{filter: "aws.eu_west", expected: []bool{true, false, false, false}},
+		{filter: "hashicorp/aws", expected: []bool{true, true, true, false}},
+		{filter: "hashicorp/aws.eu_west", expected: []bool{true, false, false, false}},
+		{filter: "registry.terraform.io/hashicorp/aws", expected: []bool{true, true, true, false}},
+		{filter: "example.com/hashicorp/aws", expected: []bool{false, false, false, false}},
+		{filter: "acme/aws", expected: []bool{false, false, true, false}},
+		{filter: "*", expected: []bool{true, true, true, true}},
+		{filter: "aws.eu_*", expected: []bool{true, false, false, false}},
+		{filter: "aws.", expected: []bool{false, true, true, false}},
+		{filter: "hashicorp/aws.", expected: []bool{false, true, true, false}},
+		{filter: "module.network.aws", expected: []bool{false, true, false, false}},
+		{filter: "module.net*.hashicorp/aws.", expected: []bool{false, true, false, false}},
+		{filter: "module.app.aws", expected: []bool{false, false, false, false}},
+	}
+
+	for _, test := range tests {
+		filter, err := state.ParseProviderFilter(test.filter)
+		assert.Nil(t, err, test.filter)
+		for index, provider := range []state.ProviderAddress{euWest, aws, legacy, null} {
+			assert.Equal(t, test.expected[index], filter.Matches(provider), "%s matching %s", test.filter, provider)
+		}
+	}
+
+	t.Run("Invalid provider filters should returns an error", func(t *testing.T) {
+		for _, input := range []string{"", ".", "/aws", "a/b/c/d", "hashicorp//aws", "module.network."} {
+			_, err := state.ParseProviderFilter(input)
+			assert.NotNil(t, err, input)
+		}
+	})
+}
+
+func TestThatFilterMatchesProviderSpecification(t *testing.T) {
+	resource := state.TerraformResource{
+		Type:     "aws_s3_bucket",
+		Name:     "logs",
+		Provider: `provider["registry.terraform.io/hashicorp/aws"].eu_west`,
+	}
+
+	assert.True(t, state.ResourceFilter{Provider: "aws"}.Matches(resource))
+	assert.True(t, state.ResourceFilter{Provider: "hashicorp/aws.eu_west"}.Matches(resource))
+	assert.True(t, state.ResourceFilter{Provider: `provider["hashicorp/aws"].eu_west`}.Matches(resource))
+	assert.False(t, state.ResourceFilter{Provider: "aws.us_east"}.Matches(resource))
+	assert.False(t, state.ResourceFilter{Provider: "aws."}.Matches(resource))
+	assert.False(t, state.ResourceFilter{Provider: `provider["registry.terraform.io/hashicorp/aws"]`}.Matches(resource))
+}
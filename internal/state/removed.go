@@ -0,0 +1,132 @@
+// Package state list all related resource to terraform state file
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Removal is a terraform removed block, removing a resource or a module from the state
+// without destroying its objects
+type Removal struct {
+	From Address
+}
+
+// String renders the removal as a terraform removed block
+func (r Removal) String() string {
+	return fmt.Sprintf("removed {\n  from = %s\n\n  lifecycle {\n    destroy = false\n  }\n}\n", r.From)
+}
+
+// FormatRemovals renders removals as terraform removed blocks separated by blank lines
+func FormatRemovals(removals []Removal) string {
+	blocks := make([]string, 0, len(removals))
+	for _, block := range removals {
+		blocks = append(blocks, block.String())
+	}
+	return strings.Join(blocks, "\n")
+}
+
+// configModule returns the module path of the configuration declaring the module instance p,
+// that is p without its instance keys
+func configModule(p ModulePath) ModulePath {
+	output := make(ModulePath, 0, len(p))
+	for _, step := range p {
+		output = append(output, ModuleInstance{Name: step.Name})
+	}
+	return output
+}
+
+// Removals returns the removed blocks of the managed resources selected by filter. A single
+// block is returned for a module when every resource of the module is selected. As removed
+// blocks can not designate instances, an error is returned when some instances of a selected
+// resource, in any instance of its modules, are not selected.
+func (s TerraformState) Removals(filter Matcher) ([]Removal, error) {
+	total := map[string]int{}
+	for _, resource := range s.Resources {
+		countRemovedInstances(total, resource)
+	}
+	selected := map[string]int{}
+	resources := []TerraformResource{}
+	for _, resource := range s.ListResources(filter) {
+		if resource.Mode == ManagedMode {
+			countRemovedInstances(selected, resource)
+			resources = append(resources, resource)
+		}
+	}
+
+	removals := []Removal{}
+	problems := []string{}
+	found := map[string]bool{}
+	for _, resource := range resources {
+		module := configModule(resource.ModulePath())
+		from := Address{Module: module, Mode: resource.Mode, Type: resource.Type, Name: resource.Name}
+		for length := 1; length <= len(module); length++ {
+			if prefix := module[:length].String(); total[prefix] == selected[prefix] {
+				from = ModuleAddress(module[:length])
+				break
+			}
+		}
+
+		key := from.String()
+		if found[key] {
+			continue
+		}
+		found[key] = true
+		if total[key] != selected[key] {
+			problems = append(problems, fmt.Sprintf("%s has instances which are not selected, removed blocks remove all of them", key))
+			continue
+		}
+		removals = append(removals, Removal{From: from})
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, errors.New(strings.Join(problems, "\n"))
+	}
+	return removals, nil
+}
+
+// countRemovedInstances counts the managed instances of resource under the configuration
+// address of the resource and of each of its modules
+func countRemovedInstances(counts map[string]int, resource TerraformResource) {
+	if resource.Mode != ManagedMode {
+		return
+	}
+	instances := 0
+	for _, instance := range resource.Instances {
+		if instance.Deposed == "" {
+			instances++
+		}
+	}
+
+	module := configModule(resource.ModulePath())
+	for length := 1; length <= len(module); length++ {
+		counts[module[:length].String()] += instances
+	}
+	counts[Address{Module: module, Mode: resource.Mode, Type: resource.Type, Name: resource.Name}.String()] += instances
+}
@@ -0,0 +1,97 @@
+/*
+MIT License
+
+# Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state_test
+
+import (
+	"testing"
+
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func removals(t *testing.T, filters ...string) ([]state.Removal, error) {
+	matcher, err := state.NewFilterSet(filters, nil)
+	assert.Nil(t, err)
+	return modulesState().Removals(matcher)
+}
+
+func TestRemovals(t *testing.T) {
+	t.Run("Whole module should be removed with a single removed block", func(t *testing.T) {
+		removed, err := removals(t, "module.legacy_vpc")
+		assert.Nil(t, err)
+		assert.Len(t, removed, 1)
+		assert.Equal(t, "removed {\n  from = module.legacy_vpc\n\n  lifecycle {\n    destroy = false\n  }\n}\n", removed[0].String())
+	})
+
+	t.Run("Nested module should be removed without its parent", func(t *testing.T) {
+		removed, err := removals(t, "module.legacy_vpc.module.subnets")
+		assert.Nil(t, err)
+		assert.Len(t, removed, 1)
+		assert.Equal(t, "module.legacy_vpc.module.subnets", removed[0].From.String())
+	})
+
+	t.Run("Partially selected module should be removed resource by resource", func(t *testing.T) {
+		removed, err := removals(t, "module.legacy_vpc.aws_vpc.main", "aws_sqs_queue.jobs", "data.*.*")
+		assert.Nil(t, err)
+		assert.Len(t, removed, 2)
+		assert.Equal(t, "module.legacy_vpc.aws_vpc.main", removed[0].From.String())
+		assert.Equal(t, "aws_sqs_queue.jobs", removed[1].From.String())
+	})
+
+	t.Run("Every instance of a module should be removed at once", func(t *testing.T) {
+		removed, err := removals(t, "module.app[*]")
+		assert.Nil(t, err)
+		assert.Len(t, removed, 1)
+		assert.Equal(t, "module.app", removed[0].From.String())
+	})
+
+	t.Run("Removals should returns an error when a module instance is selected", func(t *testing.T) {
+		_, err := removals(t, `module.app["eu"]`)
+		assert.EqualError(t, err, "module.app.aws_s3_bucket.logs has instances which are not selected, removed blocks remove all of them")
+	})
+
+	t.Run("Removals should returns an error when some instances are selected", func(t *testing.T) {
+		terraformState := state.TerraformState{Resources: []state.TerraformResource{
+			{Mode: state.ManagedMode, Type: "aws_instance", Name: "web", Instances: []state.TerraformResourceValue{
+				{IndexKey: state.IntKey(0), Attributes: map[string]interface{}{"id": "i-1"}},
+				{IndexKey: state.IntKey(1), Attributes: map[string]interface{}{"id": "i-2"}},
+			}},
+		}}
+		filter, err := state.ParseWhere(`id == "i-1"`)
+		assert.Nil(t, err)
+
+		_, err = terraformState.Removals(filter)
+		assert.EqualError(t, err, "aws_instance.web has instances which are not selected, removed blocks remove all of them")
+	})
+}
+
+func TestFormatRemovals(t *testing.T) {
+	removed := []state.Removal{
+		{From: state.Address{Mode: state.ManagedMode, Type: "aws_sqs_queue", Name: "jobs"}},
+		{From: state.Address{Mode: state.ManagedMode, Type: "aws_sqs_queue", Name: "mails"}},
+	}
+	expected := "removed {\n  from = aws_sqs_queue.jobs\n\n  lifecycle {\n    destroy = false\n  }\n}\n\n" +
+		"removed {\n  from = aws_sqs_queue.mails\n\n  lifecycle {\n    destroy = false\n  }\n}\n"
+	assert.Equal(t, expected, state.FormatRemovals(removed))
+}
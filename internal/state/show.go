@@ -0,0 +1,221 @@
+// Package state list all related resource to terraform state file
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ShowFormatVersion is the major format version of terraform show -json documents supported
+const ShowFormatVersion = "1"
+
+// showDocument is the json document written by terraform show -json for a state
+type showDocument struct {
+	FormatVersion    string      `json:"format_version"`
+	TerraformVersion string      `json:"terraform_version"`
+	Values           *showValues `json:"values"`
+	PlannedValues    *showValues `json:"planned_values"`
+}
+
+type showValues struct {
+	Outputs    map[string]showOutput `json:"outputs"`
+	RootModule showModule            `json:"root_module"`
+}
+
+type showOutput struct {
+	Sensitive bool        `json:"sensitive"`
+	Value     interface{} `json:"value"`
+	Type      *CtyType    `json:"type"`
+}
+
+type showModule struct {
+	Address      string         `json:"address"`
+	Resources    []showResource `json:"resources"`
+	ChildModules []showModule   `json:"child_modules"`
+}
+
+type showResource struct {
+	Address         string                 `json:"address"`
+	Mode            string                 `json:"mode"`
+	Type            string                 `json:"type"`
+	Name            string                 `json:"name"`
+	Index           *IndexKey              `json:"index"`
+	ProviderName    string                 `json:"provider_name"`
+	SchemaVersion   int                    `json:"schema_version"`
+	Values          map[string]interface{} `json:"values"`
+	SensitiveValues interface{}            `json:"sensitive_values"`
+	DependsOn       []string               `json:"depends_on"`
+	Tainted         bool                   `json:"tainted"`
+	DeposedKey      string                 `json:"deposed_key"`
+}
+
+// decodeJSON decodes data into value, numbers being decoded as json.Number
+func decodeJSON(data []byte, value interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(value)
+}
+
+// isShowDocument returns true if data is a document written by terraform show -json rather
+// than a state file
+func isShowDocument(data []byte) bool {
+	probe := struct {
+		FormatVersion *string `json:"format_version"`
+	}{}
+	return json.Unmarshal(data, &probe) == nil && probe.FormatVersion != nil
+}
+
+// fromShowDocument converts a document written by terraform show -json into a state. As such
+// documents have neither serial nor lineage, they are left empty, and provider aliases are lost.
+func fromShowDocument(data []byte) (*TerraformState, error) {
+	document := showDocument{}
+	if err := decodeJSON(data, &document); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrMalformedState, err)
+	}
+	if major, _, _ := strings.Cut(document.FormatVersion, "."); major != ShowFormatVersion {
+		return nil, fmt.Errorf("%w: terraform show format %s (expected %s.x)", ErrUnsupportedStateVersion, document.FormatVersion, ShowFormatVersion)
+	}
+	if document.PlannedValues != nil {
+		return nil, fmt.Errorf("%w: terraform show document of a plan instead of a state", ErrMalformedState)
+	}
+
+	terraformState := TerraformState{
+		Version:          StateFormatVersion,
+		TerraformVersion: document.TerraformVersion,
+		Outputs:          map[string]TerraformOutputValue{},
+		Resources:        []TerraformResource{},
+	}
+	if document.Values == nil {
+		return &terraformState, nil
+	}
+
+	for name, output := range document.Values.Outputs {
+		value := TerraformOutputValue{Sensitive: output.Sensitive, Value: output.Value, Type: CtyDynamic}
+		if output.Type != nil {
+			value.Type = *output.Type
+		}
+		terraformState.Outputs[name] = value
+	}
+
+	resources, err := document.Values.RootModule.stateResources()
+	if err != nil {
+		return nil, err
+	}
+	terraformState.Resources = resources
+	return &terraformState, nil
+}
+
+// stateResources returns the resources of the module and of its child modules, grouping
+// their instances as in state files
+func (m showModule) stateResources() ([]TerraformResource, error) {
+	resources := []TerraformResource{}
+	positions := map[string]int{}
+	for _, resource := range m.Resources {
+		instance := TerraformResourceValue{
+			SchemaVersion:       resource.SchemaVersion,
+			Attributes:          resource.Values,
+			SensitiveAttributes: sensitivePaths(resource.SensitiveValues, []interface{}{}),
+			Deposed:             resource.DeposedKey,
+		}
+		if resource.Index != nil {
+			instance.IndexKey = *resource.Index
+		}
+		if len(resource.DependsOn) > 0 || resource.Tainted {
+			instance.Extra = map[string]json.RawMessage{}
+			if len(resource.DependsOn) > 0 {
+				dependencies, err := json.Marshal(resource.DependsOn)
+				if err != nil {
+					return nil, err
+				}
+				instance.Extra["dependencies"] = dependencies
+			}
+			if resource.Tainted {
+				instance.Extra["status"] = json.RawMessage(`"tainted"`)
+			}
+		}
+
+		converted := TerraformResource{
+			Module:   m.Address,
+			Mode:     resource.Mode,
+			Type:     resource.Type,
+			Name:     resource.Name,
+			Provider: fmt.Sprintf("provider[%s]", strconv.Quote(resource.ProviderName)),
+			Each:     eachMode(instance.IndexKey),
+		}
+		key := converted.String()
+		position, found := positions[key]
+		if !found {
+			position = len(resources)
+			positions[key] = position
+			resources = append(resources, converted)
+		}
+		resources[position].Instances = append(resources[position].Instances, instance)
+	}
+
+	for _, child := range m.ChildModules {
+		childResources, err := child.stateResources()
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, childResources...)
+	}
+	return resources, nil
+}
+
+// sensitivePaths converts sensitive_values, a value where sensitive attributes are true,
+// into the paths of sensitive_attributes found in state files
+func sensitivePaths(sensitive interface{}, path []interface{}) []interface{} {
+	step := func(kind string, value interface{}) []interface{} {
+		return append(append([]interface{}{}, path...), map[string]interface{}{"type": kind, "value": value})
+	}
+
+	paths := []interface{}{}
+	switch value := sensitive.(type) {
+	case bool:
+		if value && len(path) > 0 {
+			paths = append(paths, path)
+		}
+	case map[string]interface{}:
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			paths = append(paths, sensitivePaths(value[name], step("get_attr", name))...)
+		}
+	case []interface{}:
+		for index, element := range value {
+			key := map[string]interface{}{"value": json.Number(strconv.Itoa(index)), "type": "number"}
+			paths = append(paths, sensitivePaths(element, step("index", key))...)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,140 @@
+/*
+MIT License
+
+# Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/stretchr/testify/assert"
+)
+
+const showDocument = `{
+  "format_version": "1.0",
+  "terraform_version": "1.5.7",
+  "values": {
+    "outputs": {
+      "password": {"sensitive": true, "value": "secret", "type": "string"}
+    },
+    "root_module": {
+      "resources": [
+        {
+          "address": "aws_instance.web[0]",
+          "mode": "managed",
+          "type": "aws_instance",
+          "name": "web",
+          "index": 0,
+          "provider_name": "registry.terraform.io/hashicorp/aws",
+          "schema_version": 1,
+          "values": {"id": "i-1", "tags": {"team": "core"}, "user_data": "x"},
+          "sensitive_values": {"tags": {}, "user_data": true},
+          "depends_on": ["aws_vpc.main"]
+        },
+        {
+          "address": "aws_instance.web[1]",
+          "mode": "managed",
+          "type": "aws_instance",
+          "name": "web",
+          "index": 1,
+          "provider_name": "registry.terraform.io/hashicorp/aws",
+          "schema_version": 1,
+          "values": {"id": "i-2", "cpu": 2},
+          "sensitive_values": {},
+          "tainted": true
+        }
+      ],
+      "child_modules": [
+        {
+          "address": "module.app[\"eu\"]",
+          "resources": [
+            {
+              "address": "module.app[\"eu\"].data.aws_vpc.main",
+              "mode": "data",
+              "type": "aws_vpc",
+              "name": "main",
+              "provider_name": "registry.terraform.io/hashicorp/aws",
+              "schema_version": 0,
+              "values": {"id": "vpc-1"},
+              "sensitive_values": {}
+            }
+          ]
+        }
+      ]
+    }
+  }
+}`
+
+func TestFromReaderShowDocument(t *testing.T) {
+	t.Run("FromReader should convert terraform show documents", func(t *testing.T) {
+		terraformState, err := state.FromReader(strings.NewReader(showDocument))
+		assert.Nil(t, err)
+		assert.Equal(t, 4, terraformState.Version)
+		assert.Equal(t, "1.5.7", terraformState.TerraformVersion)
+		assert.Equal(t, state.TerraformOutputValue{Sensitive: true, Value: "secret", Type: state.CtyString}, terraformState.Outputs["password"])
+
+		assert.Len(t, terraformState.Resources, 2)
+		web := terraformState.Resources[0]
+		assert.Equal(t, "aws_instance.web", web.String())
+		assert.Equal(t, `provider["registry.terraform.io/hashicorp/aws"]`, web.Provider)
+		assert.Equal(t, "list", web.Each)
+		assert.Len(t, web.Instances, 2)
+		assert.Equal(t, state.IntKey(1), web.Instances[1].IndexKey)
+		assert.Equal(t, json.Number("2"), web.Instances[1].Attributes["cpu"])
+		assert.Equal(t, []interface{}{[]interface{}{map[string]interface{}{"type": "get_attr", "value": "user_data"}}}, web.Instances[0].SensitiveAttributes)
+		assert.Equal(t, json.RawMessage(`["aws_vpc.main"]`), web.Instances[0].Extra["dependencies"])
+		assert.Equal(t, json.RawMessage(`"tainted"`), web.Instances[1].Extra["status"])
+
+		vpc := terraformState.Resources[1]
+		assert.Equal(t, `module.app["eu"].data.aws_vpc.main`, vpc.String())
+		assert.True(t, vpc.Instances[0].IndexKey.IsNone())
+		assert.Equal(t, "", vpc.Each)
+	})
+
+	t.Run("Sensitive attributes of terraform show documents should be redacted", func(t *testing.T) {
+		terraformState, err := state.FromReader(strings.NewReader(showDocument))
+		assert.Nil(t, err)
+		where, err := state.ParseWhere(`user_data == "x"`)
+		assert.Nil(t, err)
+
+		assert.Empty(t, terraformState.ListResources(where))
+	})
+
+	t.Run("FromReader should accept terraform show documents of empty states", func(t *testing.T) {
+		terraformState, err := state.FromReader(strings.NewReader(`{"format_version": "1.0"}`))
+		assert.Nil(t, err)
+		assert.Empty(t, terraformState.Resources)
+	})
+
+	t.Run("FromReader should returns an error for unsupported terraform show format versions", func(t *testing.T) {
+		_, err := state.FromReader(strings.NewReader(`{"format_version": "2.0"}`))
+		assert.ErrorIs(t, err, state.ErrUnsupportedStateVersion)
+	})
+
+	t.Run("FromReader should returns an error for terraform show documents of plans", func(t *testing.T) {
+		_, err := state.FromReader(strings.NewReader(`{"format_version": "1.2", "planned_values": {}}`))
+		assert.ErrorIs(t, err, state.ErrMalformedState)
+	})
+}
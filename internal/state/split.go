@@ -0,0 +1,142 @@
+// Package state list all related resource to terraform state file
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state
+
+import (
+	"crypto/rand"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RemainderName is the name of the part of a split state holding resources matched by no rule
+const RemainderName = "remainder"
+
+var splitRuleNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// SplitRule selects the resources moved to a new state when a state is split
+type SplitRule struct {
+	Name   string
+	Filter string
+	Matcher
+}
+
+// ParseSplitRule parses a rule given as name=filter, for instance network=module.vpc*
+func ParseSplitRule(input string) (*SplitRule, error) {
+	name, filter, found := strings.Cut(input, "=")
+	name, filter = strings.TrimSpace(name), strings.TrimSpace(filter)
+	if !found || filter == "" {
+		return nil, fmt.Errorf("invalid rule %q, expected name=filter", input)
+	}
+	if !splitRuleNamePattern.MatchString(name) || name == RemainderName {
+		return nil, fmt.Errorf("invalid rule name %q, expected letters, digits, _ or - other than %s", name, RemainderName)
+	}
+
+	matcher, err := NewFilterSet([]string{filter}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("rule %s: %w", name, err)
+	}
+	return &SplitRule{Name: name, Filter: filter, Matcher: matcher}, nil
+}
+
+// SplitPart is a state holding the resources selected by a split rule
+type SplitPart struct {
+	Rule  SplitRule
+	State *TerraformState
+}
+
+// Split separates the resources of the state into a new state per rule and a remainder. New
+// states are created with a fresh lineage, serial 1, the terraform version of the state and
+// no output. The remainder is a new version of the state, keeping its lineage and outputs.
+// An error is returned when a rule matches no resource, when a resource is matched by
+// several rules or when rules have the same name. States without lineage, read from terraform
+// show output, are refused with ErrNoLineage.
+func (s TerraformState) Split(rules []SplitRule) ([]SplitPart, *TerraformState, error) {
+	if s.Lineage == "" {
+		return nil, nil, ErrNoLineage
+	}
+
+	parts := make([]SplitPart, 0, len(rules))
+	names := map[string]bool{}
+	for _, rule := range rules {
+		if names[rule.Name] {
+			return nil, nil, fmt.Errorf("several rules are named %s", rule.Name)
+		}
+		names[rule.Name] = true
+
+		lineage, err := NewLineage()
+		if err != nil {
+			return nil, nil, err
+		}
+		parts = append(parts, SplitPart{Rule: rule, State: &TerraformState{
+			Version:          s.Version,
+			TerraformVersion: s.TerraformVersion,
+			Serial:           1,
+			Lineage:          lineage,
+			Outputs:          map[string]TerraformOutputValue{},
+			Resources:        []TerraformResource{},
+		}})
+	}
+
+	remainder := s
+	remainder.Serial++
+	remainder.Resources = []TerraformResource{}
+	for _, resource := range s.Resources {
+		matched := -1
+		for index, part := range parts {
+			if !part.Rule.Matches(resource) {
+				continue
+			}
+			if matched >= 0 {
+				return nil, nil, fmt.Errorf("%s is matched by rules %s and %s", resource, parts[matched].Rule.Name, part.Rule.Name)
+			}
+			matched = index
+		}
+
+		if matched < 0 {
+			remainder.Resources = append(remainder.Resources, resource)
+		} else {
+			parts[matched].State.Resources = append(parts[matched].State.Resources, resource)
+		}
+	}
+
+	for _, part := range parts {
+		if len(part.State.Resources) == 0 {
+			return nil, nil, fmt.Errorf("rule %s=%s does not match any resource", part.Rule.Name, part.Rule.Filter)
+		}
+	}
+	return parts, &remainder, nil
+}
+
+// NewLineage returns a random lineage for a new state, formatted as an UUID like terraform does
+func NewLineage() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	bytes[6] = bytes[6]&0x0f | 0x40
+	bytes[8] = bytes[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", bytes[0:4], bytes[4:6], bytes[6:8], bytes[8:10], bytes[10:16]), nil
+}
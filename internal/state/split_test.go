@@ -0,0 +1,112 @@
+/*
+MIT License
+
+# Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func splitRules(t *testing.T, values ...string) []state.SplitRule {
+	rules := []state.SplitRule{}
+	for _, value := range values {
+		rule, err := state.ParseSplitRule(value)
+		assert.Nil(t, err)
+		rules = append(rules, *rule)
+	}
+	return rules
+}
+
+func TestParseSplitRule(t *testing.T) {
+	t.Run("ParseSplitRule should returns the name and filter of the rule", func(t *testing.T) {
+		rule, err := state.ParseSplitRule("network=module.vpc*")
+		assert.Nil(t, err)
+		assert.Equal(t, "network", rule.Name)
+		assert.Equal(t, "module.vpc*", rule.Filter)
+	})
+
+	for _, value := range []string{"network", "network=", "=module.vpc", "net work=module.vpc", "remainder=module.vpc", "network=module."} {
+		t.Run("ParseSplitRule should returns an error for "+value, func(t *testing.T) {
+			_, err := state.ParseSplitRule(value)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	source := modulesState()
+	source.TerraformVersion = "1.5.0"
+	source.Serial = 12
+	source.Lineage = "source"
+	source.Outputs = map[string]state.TerraformOutputValue{"vpc_id": {Type: state.CtyString, Value: "vpc-1"}}
+
+	t.Run("Split should write resources of each rule to a new state", func(t *testing.T) {
+		parts, remainder, err := source.Split(splitRules(t, "network=module.legacy_vpc", "storage=module.app[*]"))
+		assert.Nil(t, err)
+		assert.Len(t, parts, 2)
+
+		network := parts[0].State
+		assert.Equal(t, "network", parts[0].Rule.Name)
+		assert.Len(t, network.Resources, 3)
+		assert.Equal(t, 4, network.Version)
+		assert.Equal(t, "1.5.0", network.TerraformVersion)
+		assert.Equal(t, 1, network.Serial)
+		assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), network.Lineage)
+		assert.Empty(t, network.Outputs)
+		assert.Len(t, parts[1].State.Resources, 2)
+		assert.NotEqual(t, network.Lineage, parts[1].State.Lineage)
+
+		assert.Len(t, remainder.Resources, 1)
+		assert.Equal(t, "aws_sqs_queue.jobs", remainder.Resources[0].String())
+		assert.Equal(t, 13, remainder.Serial)
+		assert.Equal(t, "source", remainder.Lineage)
+		assert.Len(t, remainder.Outputs, 1)
+		assert.Len(t, source.Resources, 6)
+	})
+
+	t.Run("Split should returns an error when a resource is matched by several rules", func(t *testing.T) {
+		_, _, err := source.Split(splitRules(t, "network=module.legacy_vpc", "subnets=module.legacy_vpc.module.subnets"))
+		assert.EqualError(t, err, "module.legacy_vpc.module.subnets.aws_subnet.private is matched by rules network and subnets")
+	})
+
+	t.Run("Split should returns an error when a rule matches no resource", func(t *testing.T) {
+		_, _, err := source.Split(splitRules(t, "network=module.vpc"))
+		assert.EqualError(t, err, "rule network=module.vpc does not match any resource")
+	})
+
+	t.Run("Split should returns an error when rules have the same name", func(t *testing.T) {
+		_, _, err := source.Split(splitRules(t, "network=module.legacy_vpc", "network=aws_sqs_queue.jobs"))
+		assert.EqualError(t, err, "several rules are named network")
+	})
+
+	t.Run("Split should returns an error for a state read from terraform show output", func(t *testing.T) {
+		show := source
+		show.Lineage = ""
+		_, _, err := show.Split(splitRules(t, "network=module.legacy_vpc"))
+		assert.ErrorIs(t, err, state.ErrNoLineage)
+	})
+}
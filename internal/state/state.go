@@ -26,36 +26,68 @@ package state
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"os"
+	"path/filepath"
 	"strings"
 )
 
+// StateFormatVersion is the only terraform state format version supported (terraform >= 0.12)
+const StateFormatVersion = 4
+
+var (
+	// ErrStateNotFound is returned when the terraform state file does not exist
+	ErrStateNotFound = errors.New("terraform state file not found")
+	// ErrMalformedState is returned when the terraform state is not a valid json document
+	ErrMalformedState = errors.New("malformed terraform state")
+	// ErrUnsupportedStateVersion is returned when the terraform state format version is not supported
+	ErrUnsupportedStateVersion = errors.New("unsupported terraform state version")
+	// ErrStateLocked is returned when a terraform state file can not be written because of a lock
+	ErrStateLocked = errors.New("terraform state is locked")
+	// ErrNoLineage is returned when a terraform state without lineage, read from terraform show
+	// output, is written as a state file, merged or split
+	ErrNoLineage = errors.New("terraform state has no lineage, terraform show output can not be written as a state file")
+)
+
+// BackupSuffix is appended to the path of a state file to get the path of its backup
+const BackupSuffix = ".backup"
+
 // TerraformOutputValue represents a value of terraform output.
 type TerraformOutputValue struct {
-	Sensitive   bool   `json:"sensitive"`
-	Type        string `json:"type"`
-	Value       int    `json:"value"`
-	Description string `json:"description"`
+	Sensitive   bool        `json:"sensitive,omitempty"`
+	Type        CtyType     `json:"type"`
+	Value       interface{} `json:"value"`
+	Description string      `json:"description,omitempty"`
 }
 
 // TerraformResourceValue represents a value of terraform resource (or module).
 type TerraformResourceValue struct {
-	IndexKey            string                 `json:"index_key"`
+	IndexKey            IndexKey               `json:"index_key"`
 	SchemaVersion       int                    `json:"schema_version"`
 	Attributes          map[string]interface{} `json:"attributes"`
 	SensitiveAttributes []interface{}          `json:"sensitive_attributes"`
+	// Deposed is the key of an object replaced with create_before_destroy but not destroyed yet
+	Deposed string `json:"deposed,omitempty"`
+	// Extra holds the fields of the instance not modelled (private, dependencies, status...),
+	// written back unchanged
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
 // TerraformResource represents terraform resource (or module).
 type TerraformResource struct {
-	Module    string                   `json:"module"`
-	Mode      string                   `json:"mode"`
-	Type      string                   `json:"type"`
-	Name      string                   `json:"name"`
+	Module string `json:"module,omitempty"`
+	Mode   string `json:"mode"`
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	// Each is list for resources created with count and map for resources created with
+	// for_each. It is only written by some terraform versions.
+	Each      string                   `json:"each,omitempty"`
 	Provider  string                   `json:"provider"`
 	Instances []TerraformResourceValue `json:"instances"`
+	// Extra holds the fields of the resource not modelled, written back unchanged
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
 // TerraformState represents terraform state.
@@ -66,61 +98,192 @@ type TerraformState struct {
 	Lineage          string                          `json:"lineage"`
 	Outputs          map[string]TerraformOutputValue `json:"outputs"`
 	Resources        []TerraformResource             `json:"resources"`
+	// Extra holds the fields of the state not modelled (check_results...), written back unchanged
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
 func (resource TerraformResource) String() string {
-	prefix := ""
-	if strings.TrimSpace(resource.Module) != "" {
-		prefix = fmt.Sprintf("%s.", resource.Module)
+	return resource.Address(NoKey).String()
+}
+
+// ModulePath returns the path of the module containing the resource
+func (resource TerraformResource) ModulePath() ModulePath {
+	module, err := ParseModulePath(resource.Module)
+	if err != nil {
+		return ModulePath{{Name: strings.TrimPrefix(resource.Module, "module.")}}
 	}
+	return module
+}
 
-	return fmt.Sprintf("%s%s.%s", prefix, resource.Type, resource.Name)
+// Address returns the address of the resource instance identified by key
+func (resource TerraformResource) Address(key IndexKey) Address {
+	return Address{
+		Module: resource.ModulePath(),
+		Mode:   resource.Mode,
+		Type:   resource.Type,
+		Name:   resource.Name,
+		Key:    key,
+	}
 }
 
-// FromReader unmarshall terraform state from a reader.
-func FromReader(reader io.Reader) TerraformState {
-	terraformState := TerraformState{}
+// FromReader unmarshall terraform state from a reader. Both state files and documents written
+// by terraform show -json are accepted. An error is returned if the reader does not contain
+// exactly one json document with a supported format version.
+func FromReader(reader io.Reader) (*TerraformState, error) {
 	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+
+	var document json.RawMessage
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrMalformedState, err)
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("%w: unexpected data after terraform state", ErrMalformedState)
+	}
+
+	terraformState := &TerraformState{}
+	if isShowDocument(document) {
+		var err error
+		if terraformState, err = fromShowDocument(document); err != nil {
+			return nil, err
+		}
+	} else if err := decodeJSON(document, terraformState); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrMalformedState, err)
+	}
+
+	if terraformState.Version != StateFormatVersion {
+		return nil, fmt.Errorf("%w: %d (expected %d)", ErrUnsupportedStateVersion, terraformState.Version, StateFormatVersion)
+	}
 
-	for {
-		err := decoder.Decode(&terraformState)
-		if err == io.EOF {
-			break
+	for _, resource := range terraformState.Resources {
+		if _, err := ParseModulePath(resource.Module); err != nil {
+			return nil, fmt.Errorf("%w: resource %s.%s: %s", ErrMalformedState, resource.Type, resource.Name, err)
 		}
+	}
+
+	return terraformState, nil
+}
+
+// FromFile unmarshall terraform state from a file path.
+func FromFile(path string) (*TerraformState, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrStateNotFound, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening terraform state file %s - %w", path, err)
+	}
+	defer file.Close()
+
+	terraformState, err := FromReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return terraformState, nil
+}
+
+// ToWriter writes the terraform state as indented json, as terraform does
+func (s TerraformState) ToWriter(writer io.Writer) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(append(data, '\n'))
+	return err
+}
+
+// LockInfoPath returns the path of the lock info file terraform creates next to a local state
+// file while it is in use
+func LockInfoPath(path string) string {
+	return filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.lock.info", filepath.Base(path)))
+}
+
+// CheckWritable returns an error when a state file can not be written to path: ErrStateLocked
+// when a lock info file is present, or an error when path is not a regular file
+func CheckWritable(path string) error {
+	if _, err := os.Stat(LockInfoPath(path)); err == nil {
+		return fmt.Errorf("%w: %s exists, make sure terraform is not running and remove it", ErrStateLocked, LockInfoPath(path))
+	}
+	if info, err := os.Stat(path); err == nil && !info.Mode().IsRegular() {
+		return fmt.Errorf("terraform state file %s is not a regular file", path)
+	}
+	return nil
+}
+
+// ToFile writes the terraform state to path. When the file already exists, it is first copied
+// to path with BackupSuffix, and ErrStateLocked is returned when a lock info file is present.
+// The new content is written to a temporary file renamed once complete. ErrNoLineage is
+// returned for states without lineage.
+func (s TerraformState) ToFile(path string) error {
+	if err := CheckWritable(path); err != nil {
+		return err
+	}
+	if s.Lineage == "" {
+		return fmt.Errorf("%w: %s", ErrNoLineage, path)
+	}
+
+	mode := os.FileMode(0o644)
+	previous, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		info, err := os.Stat(path)
 		if err != nil {
-			log.Println("Error decoding json data:", err)
-			break
+			return err
+		}
+		mode = info.Mode().Perm()
+		if err := os.WriteFile(path+BackupSuffix, previous, mode); err != nil {
+			return fmt.Errorf("error writing backup of terraform state file %s - %w", path, err)
 		}
+	case !errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("error reading terraform state file %s - %w", path, err)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), fmt.Sprintf(".%s.*", filepath.Base(path)))
+	if err != nil {
+		return fmt.Errorf("error writing terraform state file %s - %w", path, err)
 	}
+	defer os.Remove(file.Name())
 
-	return terraformState
+	if err := s.ToWriter(file); err != nil {
+		file.Close()
+		return fmt.Errorf("error writing terraform state file %s - %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("error writing terraform state file %s - %w", path, err)
+	}
+	if err := os.Chmod(file.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
 }
 
 // ListResources returns a map with two entries: Llist of resources. Resources and Modules can be
-// filtered with ResourceFilter
-func (s TerraformState) ListResources(filter ResourceFilter) []TerraformResource {
+// filtered with ResourceFilter or any other Matcher. When the matcher is also an InstanceMatcher,
+// returned resources only contain the matching instances.
+func (s TerraformState) ListResources(filter Matcher) []TerraformResource {
 	output := []TerraformResource{}
+	instanceMatcher, filterInstances := filter.(InstanceMatcher)
 	for _, resource := range s.Resources {
-		if filter.Matches(resource) {
-			output = append(output, resource)
+		if !filter.Matches(resource) {
+			continue
 		}
-	}
-	return output
-}
 
-// GenerateMovedStatement generates terraform moved statement for a resource to a newLocation
-func GenerateMovedStatement(resource TerraformResource, newLocation string) string {
-	if len(resource.Instances) == 0 {
-		return ""
-	}
-	output := ""
-	for _, instance := range resource.Instances {
-		if strings.TrimSpace(instance.IndexKey) == "" {
-			output += fmt.Sprintf("moved {\n  from = %s\n  to   = %s\n}\n\n", resource, newLocation)
-		} else {
-			output += fmt.Sprintf("moved {\n  from = %s[\"%s\"]\n  to   = %s[\"%s\"]\n}\n\n", resource, instance.IndexKey, newLocation, instance.IndexKey)
+		if filterInstances && len(resource.Instances) > 0 {
+			instances := []TerraformResourceValue{}
+			for _, instance := range resource.Instances {
+				if instanceMatcher.MatchesInstance(resource, instance) {
+					instances = append(instances, instance)
+				}
+			}
+			if len(instances) == 0 {
+				continue
+			}
+			resource.Instances = instances
 		}
-	}
 
+		output = append(output, resource)
+	}
 	return output
 }
@@ -24,6 +24,9 @@ SOFTWARE.
 package state_test
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -33,26 +36,60 @@ import (
 )
 
 func TestFromReader(t *testing.T) {
-	t.Run("Empty state file", func(t *testing.T) {
-		got := state.FromReader(strings.NewReader("{}"))
-		wanted := state.TerraformState{}
-		assert.Equal(t, got, wanted, "Terraform state read is not valid")
+	t.Run("Empty state file should returns an error", func(t *testing.T) {
+		got, err := state.FromReader(strings.NewReader("{}"))
+		assert.ErrorIs(t, err, state.ErrUnsupportedStateVersion)
+		assert.Nil(t, got)
+	})
+
+	t.Run("Malformed state file should returns an error", func(t *testing.T) {
+		got, err := state.FromReader(strings.NewReader("{\"version\": 4, \"resources\": [}"))
+		assert.ErrorIs(t, err, state.ErrMalformedState)
+		assert.Nil(t, got)
+
+		got, err = state.FromReader(strings.NewReader(""))
+		assert.ErrorIs(t, err, state.ErrMalformedState)
+		assert.Nil(t, got)
+	})
+
+	t.Run("State file with wrong field types should returns an error", func(t *testing.T) {
+		got, err := state.FromReader(strings.NewReader("{\"version\": 4, \"resources\": [{\"type\": 1}]}"))
+		assert.ErrorIs(t, err, state.ErrMalformedState)
+		assert.Nil(t, got)
+	})
+
+	t.Run("Trailing data after state should returns an error", func(t *testing.T) {
+		got, err := state.FromReader(strings.NewReader("{\"version\": 4} {\"version\": 4}"))
+		assert.ErrorIs(t, err, state.ErrMalformedState)
+		assert.Nil(t, got)
+
+		got, err = state.FromReader(strings.NewReader("{\"version\": 4}\ngarbage"))
+		assert.ErrorIs(t, err, state.ErrMalformedState)
+		assert.Nil(t, got)
+	})
+
+	t.Run("Unsupported state version should returns an error", func(t *testing.T) {
+		got, err := state.FromReader(strings.NewReader("{\"version\": 3, \"terraform_version\": \"0.11.14\"}"))
+		assert.ErrorIs(t, err, state.ErrUnsupportedStateVersion)
+		assert.Nil(t, got)
 	})
 
 	t.Run("Neither resource nor outputs in state file", func(t *testing.T) {
-		got := state.FromReader(strings.NewReader("{\"terraform_version\": \"1.1.9\", \"version\": 4, \"lineage\": \"1681d92d-0964-f5eb-73d4-6e2dfa00baca\"}"))
+		got, err := state.FromReader(strings.NewReader("{\"terraform_version\": \"1.1.9\", \"version\": 4, \"lineage\": \"1681d92d-0964-f5eb-73d4-6e2dfa00baca\"}"))
 		wanted := state.TerraformState{Version: 4, TerraformVersion: "1.1.9", Lineage: "1681d92d-0964-f5eb-73d4-6e2dfa00baca"}
-		assert.Equal(t, got, wanted, "Terraform state read is not valid")
+		assert.Nil(t, err)
+		assert.Equal(t, wanted, *got, "Terraform state read is not valid")
 	})
 
 	t.Run("No resource in state file", func(t *testing.T) {
-		got := state.FromReader(strings.NewReader("{\n  \"version\": 4,\n  \"terraform_version\": \"1.1.9\",\n  \"serial\": 454,\n  \"lineage\": \"16\",\n  \"outputs\": {\n    \"datadog_synthetics_test_count\": {\n      \"value\": 387,\n      \"type\": \"number\"\n    }\n  },\n  \"resources\": []\n}\n"))
-		wanted := state.TerraformState{Version: 4, Serial: 454, TerraformVersion: "1.1.9", Lineage: "16", Resources: []state.TerraformResource{}, Outputs: map[string]state.TerraformOutputValue{"datadog_synthetics_test_count": {Value: 387, Type: "number", Description: "", Sensitive: false}}}
-		assert.EqualValues(t, got, wanted, "Terraform state read is not valid")
+		got, err := state.FromReader(strings.NewReader("{\n  \"version\": 4,\n  \"terraform_version\": \"1.1.9\",\n  \"serial\": 454,\n  \"lineage\": \"16\",\n  \"outputs\": {\n    \"datadog_synthetics_test_count\": {\n      \"value\": 387,\n      \"type\": \"number\"\n    }\n  },\n  \"resources\": []\n}\n"))
+		wanted := state.TerraformState{Version: 4, Serial: 454, TerraformVersion: "1.1.9", Lineage: "16", Resources: []state.TerraformResource{}, Outputs: map[string]state.TerraformOutputValue{"datadog_synthetics_test_count": {Value: json.Number("387"), Type: state.CtyNumber, Description: "", Sensitive: false}}}
+		assert.Nil(t, err)
+		assert.EqualValues(t, wanted, *got, "Terraform state read is not valid")
 	})
 
 	t.Run("resource and outputs in state file", func(t *testing.T) {
-		got := state.FromReader(strings.NewReader("{\n  \"version\": 4,\n  \"terraform_version\": \"1.1.9\",\n  \"serial\": 454,\n  \"lineage\": \"16\",\n  \"outputs\": {\n    \"datadog_synthetics_test_count\": {\n      \"value\": 387,\n      \"type\": \"number\"\n    }\n  },\n  \"resources\": [\n    {\n      \"mode\": \"data\",\n      \"type\": \"aws_caller_identity\",\n      \"name\": \"current\",\n      \"provider\": \"provider[\\\"registry.terraform.io/hashicorp/aws\\\"]\",\n      \"instances\": [\n        {\n          \"schema_version\": 0,\n          \"attributes\": {\n            \"account_id\": \"123456789\",\n            \"arn\": \"arn:aws:sts::123456789:assumed-role/test/instance\",\n            \"id\": \"123456789\",\n            \"user_id\": \"ABC:instance\"\n          },\n          \"sensitive_attributes\": []\n        }\n      ]\n    },\n    {\n      \"module\": \"module.test\",\n      \"mode\": \"managed\",\n      \"type\": \"null_resource\",\n      \"name\": \"dummy_trigger\",\n      \"provider\": \"provider[\\\"registry.terraform.io/hashicorp/null\\\"]\",\n      \"instances\": [\n        {\n          \"schema_version\": 0,\n          \"attributes\": {\n            \"id\": \"123\"\n          },\n          \"sensitive_attributes\": [],\n          \"private\": \"AAA==\"\n        }\n      ]\n    }\n  ]\n}\n"))
+		got, err := state.FromReader(strings.NewReader("{\n  \"version\": 4,\n  \"terraform_version\": \"1.1.9\",\n  \"serial\": 454,\n  \"lineage\": \"16\",\n  \"outputs\": {\n    \"datadog_synthetics_test_count\": {\n      \"value\": 387,\n      \"type\": \"number\"\n    }\n  },\n  \"resources\": [\n    {\n      \"mode\": \"data\",\n      \"type\": \"aws_caller_identity\",\n      \"name\": \"current\",\n      \"provider\": \"provider[\\\"registry.terraform.io/hashicorp/aws\\\"]\",\n      \"instances\": [\n        {\n          \"schema_version\": 0,\n          \"attributes\": {\n            \"account_id\": \"123456789\",\n            \"arn\": \"arn:aws:sts::123456789:assumed-role/test/instance\",\n            \"id\": \"123456789\",\n            \"user_id\": \"ABC:instance\"\n          },\n          \"sensitive_attributes\": []\n        }\n      ]\n    },\n    {\n      \"module\": \"module.test\",\n      \"mode\": \"managed\",\n      \"type\": \"null_resource\",\n      \"name\": \"dummy_trigger\",\n      \"provider\": \"provider[\\\"registry.terraform.io/hashicorp/null\\\"]\",\n      \"instances\": [\n        {\n          \"schema_version\": 0,\n          \"attributes\": {\n            \"id\": \"123\"\n          },\n          \"sensitive_attributes\": [],\n          \"private\": \"AAA==\"\n        }\n      ]\n    }\n  ]\n}\n"))
 		wanted := state.TerraformState{
 			Version:          4,
 			Serial:           454,
@@ -90,20 +127,66 @@ func TestFromReader(t *testing.T) {
 								"id": "123",
 							},
 							SensitiveAttributes: []interface{}{},
+							Extra:               map[string]json.RawMessage{"private": json.RawMessage(`"AAA=="`)},
 						},
 					},
 				},
 			},
 			Outputs: map[string]state.TerraformOutputValue{
 				"datadog_synthetics_test_count": {
-					Value:       387,
-					Type:        "number",
+					Value:       json.Number("387"),
+					Type:        state.CtyNumber,
 					Description: "",
 					Sensitive:   false,
 				},
 			},
 		}
-		assert.EqualValues(t, got, wanted, "Terraform state read is not valid")
+		assert.Nil(t, err)
+		assert.EqualValues(t, wanted, *got, "Terraform state read is not valid")
+	})
+	t.Run("count and for_each resources in state file", func(t *testing.T) {
+		got, err := state.FromReader(strings.NewReader("{\"version\": 4, \"resources\": [{\"mode\": \"managed\", \"type\": \"aws_instance\", \"name\": \"web\", \"instances\": [{\"index_key\": 0}, {\"index_key\": 1}]}, {\"mode\": \"managed\", \"type\": \"aws_s3_bucket\", \"name\": \"logs\", \"instances\": [{\"index_key\": \"a\"}]}]}"))
+		wanted := state.TerraformState{
+			Version: 4,
+			Resources: []state.TerraformResource{
+				{
+					Mode: "managed",
+					Type: "aws_instance",
+					Name: "web",
+					Instances: []state.TerraformResourceValue{
+						{IndexKey: state.IntKey(0)},
+						{IndexKey: state.IntKey(1)},
+					},
+				},
+				{
+					Mode: "managed",
+					Type: "aws_s3_bucket",
+					Name: "logs",
+					Instances: []state.TerraformResourceValue{
+						{IndexKey: state.StringKey("a")},
+					},
+				},
+			},
+		}
+		assert.Nil(t, err)
+		assert.EqualValues(t, wanted, *got, "Terraform state read is not valid")
+	})
+}
+
+func TestFromFile(t *testing.T) {
+	t.Run("Missing state file should returns an error", func(t *testing.T) {
+		got, err := state.FromFile(filepath.Join(t.TempDir(), "missing.tfstate"))
+		assert.ErrorIs(t, err, state.ErrStateNotFound)
+		assert.Nil(t, got)
+	})
+
+	t.Run("Valid state file should be read", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "terraform.tfstate")
+		assert.Nil(t, os.WriteFile(path, []byte("{\"version\": 4, \"serial\": 2}"), 0o600))
+
+		got, err := state.FromFile(path)
+		assert.Nil(t, err)
+		assert.Equal(t, state.TerraformState{Version: 4, Serial: 2}, *got)
 	})
 }
 
@@ -166,8 +249,8 @@ func TestTerraformState_ListResources(t *testing.T) {
 			},
 			Outputs: map[string]state.TerraformOutputValue{
 				"datadog_synthetics_test_count": {
-					Value:       387,
-					Type:        "number",
+					Value:       json.Number("387"),
+					Type:        state.CtyNumber,
 					Description: "",
 					Sensitive:   false,
 				},
@@ -229,8 +312,8 @@ func TestTerraformState_ListResources(t *testing.T) {
 			},
 			Outputs: map[string]state.TerraformOutputValue{
 				"datadog_synthetics_test_count": {
-					Value:       387,
-					Type:        "number",
+					Value:       json.Number("387"),
+					Type:        state.CtyNumber,
 					Description: "",
 					Sensitive:   false,
 				},
@@ -0,0 +1,193 @@
+// Package state list all related resource to terraform state file
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidateMoves checks that moves can be applied together to the state. An error reporting
+// every problem is returned when a move targets an address already used in the state, when
+// several moves have the same target, or when moves form a cycle (a -> b -> a). Chained moves
+// (a -> b -> c) relocate the objects of the state to the end of the chain, which must be free,
+// and are returned as warnings suggesting to collapse them. Several objects of the state ending
+// at the same address once chains are followed are reported as errors.
+func (s TerraformState) ValidateMoves(moves []Move) ([]string, error) {
+	problems := []string{}
+	warnings := []string{}
+
+	instances := s.instanceAddresses()
+	sources := map[string]Address{}
+	next := map[string]Address{}
+	for _, move := range moves {
+		next[move.From.String()] = move.To
+	}
+
+	for _, move := range moves {
+		target := move.To.String()
+		if source, found := sources[target]; found {
+			problems = append(problems, fmt.Sprintf("%s and %s are both moved to %s", source, move.From, target))
+			continue
+		}
+		sources[target] = move.From
+
+		final := move.To
+		if resolved, ok := movedAddress(move.To, moves); ok && movesInstances(move, instances) {
+			final = resolved
+		}
+		if occupant, found := occupant(final, instances, moves); found {
+			problems = append(problems, fmt.Sprintf("%s can not be moved to %s which is already used by %s", move.From, final, occupant))
+		}
+	}
+	if len(problems) == 0 {
+		problems = append(problems, s.collapsedTargets(moves)...)
+	}
+
+	reported := map[string]bool{}
+	for _, move := range moves {
+		chain := []Address{move.From, move.To}
+		cyclic := false
+		for current, found := next[move.To.String()]; found && len(chain) <= len(moves)+1; current, found = next[current.String()] {
+			chain = append(chain, current)
+			if current.Equal(move.From) {
+				cyclic = true
+				break
+			}
+		}
+		if len(chain) == 2 || reported[move.From.String()] {
+			continue
+		}
+
+		rendered := make([]string, 0, len(chain))
+		for _, address := range chain {
+			rendered = append(rendered, address.String())
+		}
+		if cyclic {
+			for _, address := range chain {
+				reported[address.String()] = true
+			}
+			problems = append(problems, fmt.Sprintf("moves form a cycle: %s", strings.Join(rendered, " -> ")))
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("moves form a chain %s which could be collapsed to %s -> %s", strings.Join(rendered, " -> "), chain[0], chain[len(chain)-1]))
+	}
+
+	if len(problems) > 0 {
+		return warnings, fmt.Errorf("invalid moves:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return warnings, nil
+}
+
+// instanceAddresses returns the addresses of the resource instances of the state, grouped
+// by resource address
+func (s TerraformState) instanceAddresses() map[string][]Address {
+	addresses := map[string][]Address{}
+	for _, resource := range s.Resources {
+		for _, instance := range resource.Instances {
+			address := resource.Address(instance.IndexKey)
+			addresses[resource.String()] = append(addresses[resource.String()], address)
+		}
+	}
+	return addresses
+}
+
+// movesInstances returns true if move relocates at least one resource instance of the state
+func movesInstances(move Move, instances map[string][]Address) bool {
+	for _, addresses := range instances {
+		for _, address := range addresses {
+			if _, ok := move.apply(address); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// collapsedTargets reports the resource instances of the state ending at the same address once
+// chained moves are followed, in the order of the state
+func (s TerraformState) collapsedTargets(moves []Move) []string {
+	problems := []string{}
+	sources := map[string]Address{}
+	for _, resource := range s.Resources {
+		for _, instance := range resource.Instances {
+			if instance.Deposed != "" {
+				continue
+			}
+			from := resource.Address(instance.IndexKey)
+			to, ok := movedAddress(from, moves)
+			if !ok {
+				continue
+			}
+			if source, found := sources[to.String()]; found {
+				problems = append(problems, fmt.Sprintf("%s and %s are both moved to %s", source, from, to))
+				continue
+			}
+			sources[to.String()] = from
+		}
+	}
+	return problems
+}
+
+// occupant returns the address of a resource instance conflicting with target which is not
+// moved away by moves. An instance conflicts with a resource instance target when it has the
+// same address, or when it belongs to the same resource with another kind of key (aws_vpc.main
+// and aws_vpc.main[0]). When target is a module, any resource instance of the module is an
+// occupant.
+func occupant(target Address, instances map[string][]Address, moves []Move) (Address, bool) {
+	if !target.IsModule() {
+		for _, address := range instances[target.Resource().String()] {
+			if (address.Equal(target) || address.Key.kind != target.Key.kind) && !isMovedAway(address, moves) {
+				return address, true
+			}
+		}
+		return Address{}, false
+	}
+
+	occupied := Address{}
+	found := false
+	for _, addresses := range instances {
+		for _, address := range addresses {
+			if !address.Module.HasPrefix(target.Module) || isMovedAway(address, moves) {
+				continue
+			}
+			if !found || address.String() < occupied.String() {
+				occupied, found = address, true
+			}
+		}
+	}
+	return occupied, found
+}
+
+// isMovedAway returns true if a move relocates the resource instance at address, either
+// directly or along with its module
+func isMovedAway(address Address, moves []Move) bool {
+	for _, move := range moves {
+		if _, ok := move.apply(address); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+/*
+MIT License
+
+# Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state_test
+
+import (
+	"testing"
+
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func mustAddress(t *testing.T, input string) state.Address {
+	address, err := state.ParseAddress(input)
+	assert.Nil(t, err)
+	return address
+}
+
+func mustModuleAddress(t *testing.T, input string) state.Address {
+	module, err := state.ParseModuleAddress(input)
+	assert.Nil(t, err)
+	return state.ModuleAddress(module)
+}
+
+func TestValidateMoves(t *testing.T) {
+	t.Run("Valid moves should not returns any error", func(t *testing.T) {
+		moves := []state.Move{
+			{From: mustAddress(t, "module.old_orders.aws_sqs_queue.main"), To: mustAddress(t, "aws_sqs_queue.orders")},
+			{From: mustModuleAddress(t, "module.old_billing"), To: mustModuleAddress(t, "module.billing")},
+		}
+
+		warnings, err := queuesState().ValidateMoves(moves)
+		assert.Nil(t, err)
+		assert.Empty(t, warnings)
+	})
+
+	t.Run("Duplicate targets should be reported", func(t *testing.T) {
+		moves := []state.Move{
+			{From: mustAddress(t, "module.old_orders.aws_sqs_queue.main"), To: mustAddress(t, "aws_sqs_queue.main")},
+			{From: mustAddress(t, "module.old_billing.aws_sns_topic.main"), To: mustAddress(t, "aws_sqs_queue.main")},
+		}
+
+		_, err := queuesState().ValidateMoves(moves)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "are both moved to aws_sqs_queue.main")
+	})
+
+	t.Run("Moves onto occupied addresses should be reported", func(t *testing.T) {
+		moves := []state.Move{
+			{From: mustAddress(t, "module.old_orders.aws_sqs_queue.main"), To: mustAddress(t, "module.old_billing.aws_sns_topic.main")},
+		}
+
+		_, err := queuesState().ValidateMoves(moves)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "already used by module.old_billing.aws_sns_topic.main")
+	})
+
+	t.Run("Moves mixing keyed and single instances should be reported", func(t *testing.T) {
+		moves := []state.Move{
+			{From: mustAddress(t, "module.old_orders.aws_sqs_queue.main"), To: mustAddress(t, "module.old_billing.aws_sqs_queue.dlq")},
+		}
+
+		_, err := queuesState().ValidateMoves(moves)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "already used by module.old_billing.aws_sqs_queue.dlq[0]")
+	})
+
+	t.Run("Moves onto occupied modules should be reported", func(t *testing.T) {
+		moves := []state.Move{
+			{From: mustModuleAddress(t, "module.old_orders"), To: mustModuleAddress(t, "module.old_billing")},
+		}
+
+		_, err := queuesState().ValidateMoves(moves)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "already used by module.old_billing.aws_sns_topic.main")
+	})
+
+	t.Run("Addresses moved away should be followed by chained moves", func(t *testing.T) {
+		moves := []state.Move{
+			{From: mustModuleAddress(t, "module.old_billing"), To: mustModuleAddress(t, "module.billing")},
+			{From: mustAddress(t, "module.old_orders.aws_sqs_queue.main"), To: mustAddress(t, "module.old_billing.aws_sns_topic.main")},
+		}
+
+		_, err := queuesState().ValidateMoves(moves)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "module.old_orders.aws_sqs_queue.main and module.old_billing.aws_sns_topic.main are both moved to module.billing.aws_sns_topic.main")
+	})
+
+	t.Run("Chains collapsing several objects of the state should be reported", func(t *testing.T) {
+		nulls := state.TerraformState{Version: 4, Resources: []state.TerraformResource{
+			{Mode: state.ManagedMode, Type: "null_resource", Name: "a", Instances: []state.TerraformResourceValue{{}}},
+			{Mode: state.ManagedMode, Type: "null_resource", Name: "b", Instances: []state.TerraformResourceValue{{}}},
+		}}
+		moves := []state.Move{
+			{From: mustAddress(t, "null_resource.a"), To: mustAddress(t, "null_resource.b")},
+			{From: mustAddress(t, "null_resource.b"), To: mustAddress(t, "null_resource.c")},
+		}
+
+		_, err := nulls.ValidateMoves(moves)
+		assert.EqualError(t, err, "invalid moves:\n  null_resource.a and null_resource.b are both moved to null_resource.c")
+		_, err = nulls.ApplyMoves(moves)
+		assert.NotNil(t, err)
+
+		nulls.Resources = nulls.Resources[:1]
+		warnings, err := nulls.ValidateMoves(moves)
+		assert.Nil(t, err)
+		assert.Len(t, warnings, 1)
+	})
+
+	t.Run("Cycles should be reported once", func(t *testing.T) {
+		moves := []state.Move{
+			{From: mustModuleAddress(t, "module.old_orders"), To: mustModuleAddress(t, "module.old_billing")},
+			{From: mustModuleAddress(t, "module.old_billing"), To: mustModuleAddress(t, "module.old_orders")},
+		}
+
+		_, err := queuesState().ValidateMoves(moves)
+		assert.NotNil(t, err)
+		assert.Equal(t, "invalid moves:\n  moves form a cycle: module.old_orders -> module.old_billing -> module.old_orders", err.Error())
+	})
+
+	t.Run("Chains should be returned as warnings", func(t *testing.T) {
+		moves := []state.Move{
+			{From: mustAddress(t, "module.old_orders.aws_sqs_queue.main"), To: mustAddress(t, "aws_sqs_queue.orders")},
+			{From: mustAddress(t, "aws_sqs_queue.orders"), To: mustAddress(t, "aws_sqs_queue.main")},
+		}
+
+		warnings, err := queuesState().ValidateMoves(moves)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"moves form a chain module.old_orders.aws_sqs_queue.main -> aws_sqs_queue.orders -> aws_sqs_queue.main which could be collapsed to module.old_orders.aws_sqs_queue.main -> aws_sqs_queue.main"}, warnings)
+	})
+}
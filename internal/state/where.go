@@ -0,0 +1,654 @@
+// Package state list all related resource to terraform state file
+/*
+MIT License
+
+Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// WhereExpression is a boolean expression evaluated against the attributes of resource
+// instances, for instance: tags.team == "payments" && instance_type =~ "^m5".
+//
+// Comparisons are written path operator literal where path navigates nested maps and lists
+// (tags.team, ingress[0].from_port, ingress.from_port matching any element of the list).
+// Supported operators are ==, !=, =~ (regular expression), !~, <, <=, > and >=. A path
+// without operator is true when the attribute exists and is neither null, false nor empty.
+// Comparisons can be combined with &&, || and !, and grouped with parentheses. Attributes
+// listed in sensitive_attributes are never matched.
+type WhereExpression struct {
+	source string
+	root   whereNode
+}
+
+// ParseWhere parses a where expression
+func ParseWhere(input string) (*WhereExpression, error) {
+	tokens, err := tokenizeWhere(input)
+	if err != nil {
+		return nil, fmt.Errorf("invalid where expression %q: %w", input, err)
+	}
+
+	parser := whereParser{tokens: tokens}
+	root, err := parser.parseOr()
+	if err == nil && !parser.done() {
+		err = fmt.Errorf("unexpected %q", parser.peek().text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid where expression %q: %w", input, err)
+	}
+
+	return &WhereExpression{source: input, root: root}, nil
+}
+
+func (w WhereExpression) String() string {
+	return w.source
+}
+
+// Matches returns true if at least one instance of the resource matches the expression
+func (w WhereExpression) Matches(resource TerraformResource) bool {
+	for _, instance := range resource.Instances {
+		if w.MatchesInstance(resource, instance) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesInstance returns true if the attributes of instance match the expression
+func (w WhereExpression) MatchesInstance(resource TerraformResource, instance TerraformResourceValue) bool {
+	return w.root.eval(attributeScope{
+		attributes: instance.Attributes,
+		sensitive:  parseSensitivePaths(instance.SensitiveAttributes),
+	})
+}
+
+// attributePath is a path to an attribute value, made of attribute names (string) and
+// list indexes (int)
+type attributePath []interface{}
+
+func (p attributePath) hasPrefix(prefix attributePath) bool {
+	if len(prefix) > len(p) {
+		return false
+	}
+	for index, step := range prefix {
+		if fmt.Sprint(step) != fmt.Sprint(p[index]) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseSensitivePaths decodes sensitive_attributes, a list of paths whose steps are
+// {"type": "get_attr", "value": "name"} or {"type": "index", "value": {"value": 0, "type": "number"}}
+func parseSensitivePaths(sensitiveAttributes []interface{}) []attributePath {
+	paths := []attributePath{}
+	for _, rawPath := range sensitiveAttributes {
+		steps, ok := rawPath.([]interface{})
+		if !ok {
+			continue
+		}
+
+		path := attributePath{}
+		for _, rawStep := range steps {
+			step, ok := rawStep.(map[string]interface{})
+			if !ok {
+				break
+			}
+			switch step["type"] {
+			case "get_attr":
+				path = append(path, fmt.Sprint(step["value"]))
+			case "index":
+				key, _ := step["value"].(map[string]interface{})
+				switch value := key["value"].(type) {
+				case json.Number:
+					index, err := value.Int64()
+					if err != nil {
+						path = append(path, value.String())
+					} else {
+						path = append(path, int(index))
+					}
+				default:
+					path = append(path, fmt.Sprint(value))
+				}
+			}
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+type attributeScope struct {
+	attributes map[string]interface{}
+	sensitive  []attributePath
+}
+
+type resolvedValue struct {
+	path  attributePath
+	value interface{}
+}
+
+// isSensitive returns true if the value at path is sensitive or holds sensitive values, which
+// expressions never match
+func (s attributeScope) isSensitive(path attributePath) bool {
+	return s.isRedacted(path) || s.holdsSensitive(path)
+}
+
+// isRedacted returns true if the value at path is sensitive or part of a sensitive value
+func (s attributeScope) isRedacted(path attributePath) bool {
+	for _, sensitivePath := range s.sensitive {
+		if path.hasPrefix(sensitivePath) {
+			return true
+		}
+	}
+	return false
+}
+
+// holdsSensitive returns true if a sensitive value is found below path
+func (s attributeScope) holdsSensitive(path attributePath) bool {
+	for _, sensitivePath := range s.sensitive {
+		if sensitivePath.hasPrefix(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the non sensitive values found at path. Steps applied to a list are
+// applied to each of its elements.
+func (s attributeScope) resolve(path []pathStep) []resolvedValue {
+	values := []resolvedValue{{path: attributePath{}, value: s.attributes}}
+	for _, step := range path {
+		next := []resolvedValue{}
+		for _, current := range values {
+			next = append(next, step.apply(current)...)
+		}
+		values = next
+	}
+
+	output := []resolvedValue{}
+	for _, value := range values {
+		if !s.isSensitive(value.path) {
+			output = append(output, value)
+		}
+	}
+	return output
+}
+
+type pathStep struct {
+	name  string
+	index int
+	isKey bool
+}
+
+func (p pathStep) apply(current resolvedValue) []resolvedValue {
+	child := func(key interface{}, value interface{}) resolvedValue {
+		path := append(append(attributePath{}, current.path...), key)
+		return resolvedValue{path: path, value: value}
+	}
+
+	switch value := current.value.(type) {
+	case map[string]interface{}:
+		key := p.name
+		if !p.isKey {
+			key = strconv.Itoa(p.index)
+		}
+		if element, ok := value[key]; ok {
+			return []resolvedValue{child(key, element)}
+		}
+	case []interface{}:
+		if !p.isKey {
+			if p.index < len(value) {
+				return []resolvedValue{child(p.index, value[p.index])}
+			}
+			return nil
+		}
+		output := []resolvedValue{}
+		for index, element := range value {
+			output = append(output, p.apply(child(index, element))...)
+		}
+		return output
+	}
+	return nil
+}
+
+type whereNode interface {
+	eval(scope attributeScope) bool
+}
+
+type notNode struct{ operand whereNode }
+
+func (n notNode) eval(scope attributeScope) bool { return !n.operand.eval(scope) }
+
+type andNode struct{ left, right whereNode }
+
+func (n andNode) eval(scope attributeScope) bool { return n.left.eval(scope) && n.right.eval(scope) }
+
+type orNode struct{ left, right whereNode }
+
+func (n orNode) eval(scope attributeScope) bool { return n.left.eval(scope) || n.right.eval(scope) }
+
+type comparisonNode struct {
+	path     []pathStep
+	operator string
+	literal  interface{}
+	pattern  *regexp.Regexp
+}
+
+// eval returns true if one of the values found at path satisfies the comparison. Lists
+// are compared element by element.
+func (n comparisonNode) eval(scope attributeScope) bool {
+	values := scope.resolve(n.path)
+	if n.operator == "!=" || n.operator == "!~" {
+		if len(values) == 0 {
+			return false
+		}
+		for _, value := range values {
+			if !n.compareAll(value.value) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, value := range values {
+		if n.compareAny(value.value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n comparisonNode) compareAny(value interface{}) bool {
+	if list, ok := value.([]interface{}); ok && n.operator != "" {
+		for _, element := range list {
+			if n.compare(element) {
+				return true
+			}
+		}
+		return false
+	}
+	return n.compare(value)
+}
+
+func (n comparisonNode) compareAll(value interface{}) bool {
+	if list, ok := value.([]interface{}); ok {
+		for _, element := range list {
+			if !n.compare(element) {
+				return false
+			}
+		}
+		return true
+	}
+	return n.compare(value)
+}
+
+func (n comparisonNode) compare(value interface{}) bool {
+	switch n.operator {
+	case "":
+		return isTruthy(value)
+	case "==":
+		return valuesEqual(value, n.literal)
+	case "!=":
+		return !valuesEqual(value, n.literal)
+	case "=~", "!~":
+		text, ok := scalarString(value)
+		if !ok {
+			return false
+		}
+		return n.pattern.MatchString(text) == (n.operator == "=~")
+	default:
+		left, okLeft := toNumber(value)
+		right, okRight := toNumber(n.literal)
+		if !okLeft || !okRight {
+			return false
+		}
+		switch n.operator {
+		case "<":
+			return left < right
+		case "<=":
+			return left <= right
+		case ">":
+			return left > right
+		default:
+			return left >= right
+		}
+	}
+}
+
+func isTruthy(value interface{}) bool {
+	switch typed := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return typed
+	case string:
+		return typed != ""
+	case []interface{}:
+		return len(typed) > 0
+	case map[string]interface{}:
+		return len(typed) > 0
+	default:
+		return true
+	}
+}
+
+func valuesEqual(value interface{}, literal interface{}) bool {
+	if literal == nil {
+		return value == nil
+	}
+	if number, ok := literal.(float64); ok {
+		actual, ok := toNumber(value)
+		return ok && actual == number
+	}
+	if boolean, ok := literal.(bool); ok {
+		actual, ok := value.(bool)
+		return ok && actual == boolean
+	}
+	text, ok := scalarString(value)
+	return ok && text == literal
+}
+
+func scalarString(value interface{}) (string, bool) {
+	switch typed := value.(type) {
+	case string:
+		return typed, true
+	case json.Number:
+		return typed.String(), true
+	case float64:
+		return strconv.FormatFloat(typed, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(typed), true
+	default:
+		return "", false
+	}
+}
+
+func toNumber(value interface{}) (float64, bool) {
+	switch typed := value.(type) {
+	case json.Number:
+		number, err := typed.Float64()
+		return number, err == nil
+	case float64:
+		return typed, true
+	case string:
+		number, err := strconv.ParseFloat(typed, 64)
+		return number, err == nil
+	default:
+		return 0, false
+	}
+}
+
+type whereTokenKind int
+
+const (
+	identToken whereTokenKind = iota
+	stringToken
+	numberToken
+	symbolToken
+)
+
+type whereToken struct {
+	kind whereTokenKind
+	text string
+}
+
+var whereSymbols = []string{"&&", "||", "==", "!=", "=~", "!~", "<=", ">=", "<", ">", "!", "(", ")", ".", "[", "]"}
+
+func tokenizeWhere(input string) ([]whereToken, error) {
+	tokens := []whereToken{}
+	for position := 0; position < len(input); {
+		char := rune(input[position])
+		switch {
+		case unicode.IsSpace(char):
+			position++
+		case char == '"':
+			end := position + 1
+			for end < len(input) && input[end] != '"' {
+				if input[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(input) {
+				return nil, fmt.Errorf("unterminated string at position %d", position)
+			}
+			value, err := strconv.Unquote(input[position : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at position %d", position)
+			}
+			tokens = append(tokens, whereToken{kind: stringToken, text: value})
+			position = end + 1
+		case unicode.IsDigit(char) || (char == '-' && position+1 < len(input) && unicode.IsDigit(rune(input[position+1]))):
+			end := position + 1
+			for end < len(input) && (unicode.IsDigit(rune(input[end])) || input[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, whereToken{kind: numberToken, text: input[position:end]})
+			position = end
+		case unicode.IsLetter(char) || char == '_':
+			end := position + 1
+			for end < len(input) && (unicode.IsLetter(rune(input[end])) || unicode.IsDigit(rune(input[end])) || strings.ContainsRune("_-", rune(input[end]))) {
+				end++
+			}
+			tokens = append(tokens, whereToken{kind: identToken, text: input[position:end]})
+			position = end
+		default:
+			matched := false
+			for _, symbol := range whereSymbols {
+				if strings.HasPrefix(input[position:], symbol) {
+					tokens = append(tokens, whereToken{kind: symbolToken, text: symbol})
+					position += len(symbol)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at position %d", char, position)
+			}
+		}
+	}
+	return tokens, nil
+}
+
+type whereParser struct {
+	tokens   []whereToken
+	position int
+}
+
+func (p *whereParser) done() bool {
+	return p.position >= len(p.tokens)
+}
+
+func (p *whereParser) peek() whereToken {
+	if p.done() {
+		return whereToken{kind: symbolToken, text: "end of expression"}
+	}
+	return p.tokens[p.position]
+}
+
+func (p *whereParser) accept(symbol string) bool {
+	if !p.done() && p.peek().kind == symbolToken && p.peek().text == symbol {
+		p.position++
+		return true
+	}
+	return false
+}
+
+func (p *whereParser) parseOr() (whereNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *whereParser) parseAnd() (whereNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *whereParser) parseUnary() (whereNode, error) {
+	if p.accept("!") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+	if p.accept("(") {
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, fmt.Errorf("expected ) instead of %q", p.peek().text)
+		}
+		return node, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *whereParser) parseComparison() (whereNode, error) {
+	path, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+
+	node := comparisonNode{path: path}
+	for _, operator := range []string{"==", "!=", "=~", "!~", "<=", ">=", "<", ">"} {
+		if p.accept(operator) {
+			node.operator = operator
+			break
+		}
+	}
+	if node.operator == "" {
+		return node, nil
+	}
+
+	literal := p.peek()
+	if p.done() {
+		return nil, fmt.Errorf("missing value after %s", node.operator)
+	}
+	p.position++
+	switch {
+	case literal.kind == stringToken:
+		node.literal = literal.text
+	case literal.kind == numberToken:
+		number, err := strconv.ParseFloat(literal.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", literal.text)
+		}
+		node.literal = number
+	case literal.kind == identToken && (literal.text == "true" || literal.text == "false"):
+		node.literal = literal.text == "true"
+	case literal.kind == identToken && literal.text == "null":
+		node.literal = nil
+	default:
+		return nil, fmt.Errorf("expected a string, a number, true, false or null instead of %q", literal.text)
+	}
+
+	if node.operator == "=~" || node.operator == "!~" {
+		text, ok := node.literal.(string)
+		if !ok {
+			return nil, fmt.Errorf("operator %s expects a string", node.operator)
+		}
+		node.pattern, err = regexp.Compile(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %w", text, err)
+		}
+	}
+	if (node.operator == "<" || node.operator == "<=" || node.operator == ">" || node.operator == ">=") && !isNumberLiteral(node.literal) {
+		return nil, fmt.Errorf("operator %s expects a number", node.operator)
+	}
+
+	return node, nil
+}
+
+func isNumberLiteral(literal interface{}) bool {
+	_, ok := literal.(float64)
+	return ok
+}
+
+func (p *whereParser) parsePath() ([]pathStep, error) {
+	token := p.peek()
+	if p.done() || token.kind != identToken {
+		return nil, fmt.Errorf("expected an attribute name instead of %q", token.text)
+	}
+	p.position++
+	path := []pathStep{{name: token.text, isKey: true}}
+
+	for {
+		switch {
+		case p.accept("."):
+			token := p.peek()
+			if p.done() || token.kind != identToken {
+				return nil, fmt.Errorf("expected an attribute name instead of %q", token.text)
+			}
+			p.position++
+			path = append(path, pathStep{name: token.text, isKey: true})
+		case p.accept("["):
+			token := p.peek()
+			p.position++
+			switch token.kind {
+			case numberToken:
+				index, err := strconv.Atoi(token.text)
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("invalid index %q", token.text)
+				}
+				path = append(path, pathStep{index: index})
+			case stringToken:
+				path = append(path, pathStep{name: token.text, isKey: true})
+			default:
+				return nil, fmt.Errorf("expected an index or a key instead of %q", token.text)
+			}
+			if !p.accept("]") {
+				return nil, fmt.Errorf("expected ] instead of %q", p.peek().text)
+			}
+		default:
+			return path, nil
+		}
+	}
+}
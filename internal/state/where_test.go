@@ -0,0 +1,138 @@
+/*
+MIT License
+
+# Copyright 2022 - © David Drugeon-Hamon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package state_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ddrugeon/terrafactor/internal/state"
+	"github.com/stretchr/testify/assert"
+)
+
+const whereState = `{
+  "version": 4,
+  "resources": [
+    {
+      "mode": "managed",
+      "type": "aws_instance",
+      "name": "web",
+      "instances": [
+        {
+          "index_key": 0,
+          "attributes": {
+            "instance_type": "m5.large",
+            "cpu_core_count": 4,
+            "monitoring": true,
+            "tags": {"team": "payments", "env": "prod"},
+            "security_groups": ["sg-1", "sg-2"],
+            "ebs_block_device": [{"device_name": "/dev/sdb", "volume_size": 100}],
+            "user_data": "secret"
+          },
+          "sensitive_attributes": [[{"type": "get_attr", "value": "user_data"}], [{"type": "get_attr", "value": "tags"}, {"type": "index", "value": {"value": "env", "type": "string"}}]]
+        },
+        {
+          "index_key": 1,
+          "attributes": {
+            "instance_type": "t3.micro",
+            "cpu_core_count": 1,
+            "monitoring": false,
+            "tags": {"team": "core"},
+            "security_groups": [],
+            "ebs_block_device": [],
+            "user_data": null
+          },
+          "sensitive_attributes": []
+        }
+      ]
+    }
+  ]
+}`
+
+func TestWhereExpression(t *testing.T) {
+	terraformState, err := state.FromReader(strings.NewReader(whereState))
+	assert.Nil(t, err)
+	resource := terraformState.Resources[0]
+
+	tests := []struct {
+		expression string
+		expected   []bool
+	}{
+		{expression: `tags.team == "payments"`, expected: []bool{true, false}},
+		{expression: `tags["team"] != "payments"`, expected: []bool{false, true}},
+		{expression: `instance_type =~ "^m5"`, expected: []bool{true, false}},
+		{expression: `instance_type !~ "^m5"`, expected: []bool{false, true}},
+		{expression: `tags.team == "payments" && instance_type =~ "^m5"`, expected: []bool{true, false}},
+		{expression: `tags.team == "core" || cpu_core_count >= 4`, expected: []bool{true, true}},
+		{expression: `!(tags.team == "core")`, expected: []bool{true, false}},
+		{expression: `cpu_core_count > 1`, expected: []bool{true, false}},
+		{expression: `cpu_core_count == 1`, expected: []bool{false, true}},
+		{expression: `monitoring`, expected: []bool{true, false}},
+		{expression: `monitoring == false`, expected: []bool{false, true}},
+		{expression: `security_groups == "sg-2"`, expected: []bool{true, false}},
+		{expression: `security_groups[0] == "sg-1"`, expected: []bool{true, false}},
+		{expression: `ebs_block_device.volume_size >= 100`, expected: []bool{true, false}},
+		{expression: `ebs_block_device[0].device_name == "/dev/sdb"`, expected: []bool{true, false}},
+		{expression: `tags.owner`, expected: []bool{false, false}},
+		{expression: `tags.owner != "nobody"`, expected: []bool{false, false}},
+		{expression: `user_data == null`, expected: []bool{false, true}},
+	}
+
+	for _, test := range tests {
+		where, err := state.ParseWhere(test.expression)
+		assert.Nil(t, err, test.expression)
+		for index, instance := range resource.Instances {
+			assert.Equal(t, test.expected[index], where.MatchesInstance(resource, instance), "%s on instance %d", test.expression, index)
+		}
+	}
+
+	t.Run("Sensitive attributes should never match", func(t *testing.T) {
+		for _, expression := range []string{`user_data == "secret"`, `user_data != "other"`, `user_data =~ ".*"`, `user_data`, `tags.env == "prod"`, `tags.env`} {
+			where, err := state.ParseWhere(expression)
+			assert.Nil(t, err, expression)
+			assert.False(t, where.MatchesInstance(resource, resource.Instances[0]), expression)
+		}
+	})
+
+	t.Run("ListResources should only keep matching instances", func(t *testing.T) {
+		where, err := state.ParseWhere(`tags.team == "core"`)
+		assert.Nil(t, err)
+
+		resources := terraformState.ListResources(state.MatchAll(state.ResourceFilter{Type: "aws_instance"}, where))
+		assert.Len(t, resources, 1)
+		assert.Len(t, resources[0].Instances, 1)
+		assert.Equal(t, state.IntKey(1), resources[0].Instances[0].IndexKey)
+
+		where, err = state.ParseWhere(`tags.team == "nobody"`)
+		assert.Nil(t, err)
+		assert.Empty(t, terraformState.ListResources(where))
+	})
+
+	t.Run("Invalid expressions should returns an error", func(t *testing.T) {
+		for _, expression := range []string{``, `tags.`, `tags.team ==`, `tags.team == payments`, `(tags.team == "a"`, `tags.team == "a" &&`, `name =~ "("`, `count > "a"`, `name == "a" name`, `tags[-1] == "a"`, `"a" == name`, `name @ 1`} {
+			_, err := state.ParseWhere(expression)
+			assert.NotNil(t, err, expression)
+		}
+	})
+}